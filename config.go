@@ -15,25 +15,179 @@
 package ecdysis
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
 	"os"
+	"path"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
 	EnvPrefix     string
 	Parsed        any
 	DefaultValues any
-	Path          string
+	// Path is the path to the config file. If it is "-", the config is read
+	// from the standard input of the command (e.g. `cat cfg.yaml | mycli`).
+	// The standard input is read once per execution, parsing the config again
+	// during the execution (e.g. in ConfigDiff) reuses the config read before.
+	Path string
+	// Type is the format of the config (e.g. "yaml" or "json"). If empty, the
+	// format of a config file is determined by its extension, while config
+	// read from the standard input is expected to be YAML.
+	Type string
+
+	// ConfigPathEnv is the name of an environment variable containing the path
+	// to the config file (e.g. "APP_CONFIG"). If it is not set, the variable
+	// <EnvPrefix>_CONFIG_PATH is checked. The environment is only checked if
+	// Path is empty, so a path provided explicitly (e.g. by a flag) wins.
+	ConfigPathEnv string
+
+	// EnvName is the name of an environment variable containing the name of
+	// the environment the CLI runs in (e.g. "MYCLI_ENV"). If it is set (e.g.
+	// to "staging"), the config file with the environment name inserted before
+	// the extension (e.g. "config.staging.yaml") is preferred over the config
+	// file in the path (e.g. "config.yaml"), if it exists.
+	EnvName string
+
+	// MutuallyExclusive contains groups of config keys of which at most one
+	// can be set. Keys are checked regardless of their source (config file,
+	// environment variable, flag or inline config), default values are
+	// ignored.
+	MutuallyExclusive [][]string
+
+	// MapstructureKeys makes DefaultValues keyed by the mapstructure tags of
+	// the fields (nested tags joined with dots) instead of their long names.
+	// Set it when the flags are built using WithMapstructureConfigKeys.
+	MapstructureKeys bool
+
+	// EmbeddedDefault is a config file shipped with the binary (e.g. using
+	// embed.FS) containing the default configuration. Its values are applied
+	// on top of DefaultValues and are overridden by all other sources.
+	EmbeddedDefault EmbeddedConfig
+}
+
+// EmbeddedConfig points to a config file in a file system. The format of the
+// file is determined by its extension (e.g. "yaml" or "json").
+type EmbeddedConfig struct {
+	// FS is the file system containing the config file. If nil, no embedded
+	// config is used.
+	FS fs.FS
+	// Path is the path to the config file in FS.
+	Path string
+}
+
+// stdinConfigPath is the config path used to read the config from the standard
+// input.
+const stdinConfigPath = "-"
+
+type stdinConfigCtxKey struct{}
+
+// stdinConfig holds the config read from the standard input during a single
+// execution of a command. The standard input can only be read once, keeping
+// the config allows parsing it again (e.g. in ConfigDiff).
+type stdinConfig struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// contextWithStdinConfig returns a copy of the context with an empty holder of
+// the config in the standard input, which is filled on the first read.
+func contextWithStdinConfig(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stdinConfigCtxKey{}, &stdinConfig{})
+}
+
+// readStdinConfig returns a reader of the config in the standard input of the
+// command. The input is read on the first call in an execution of the command,
+// later calls in the same execution return the same config.
+func readStdinConfig(cmd *cobra.Command) (io.Reader, error) {
+	var sc *stdinConfig
+	if ctx := cmd.Context(); ctx != nil {
+		sc, _ = ctx.Value(stdinConfigCtxKey{}).(*stdinConfig)
+	}
+	if sc == nil {
+		// not executing, the input is not kept
+		return cmd.InOrStdin(), nil
+	}
+	sc.once.Do(func() {
+		sc.data, sc.err = io.ReadAll(cmd.InOrStdin())
+	})
+	if sc.err != nil {
+		return nil, sc.err
+	}
+	return bytes.NewReader(sc.data), nil
+}
+
+// configPath returns the path to the config file, taking into account the
+// environment variables ConfigPathEnv, <EnvPrefix>_CONFIG_PATH and EnvName.
+// The environment variables containing the path are only checked if Path is
+// empty.
+func (c Config) configPath() string {
+	if c.Path != "" {
+		return c.envSpecificPath(c.Path)
+	}
+
+	var envs []string
+	if c.ConfigPathEnv != "" {
+		envs = append(envs, c.ConfigPathEnv)
+	}
+	if c.EnvPrefix != "" {
+		envs = append(envs, c.EnvPrefix+"_CONFIG_PATH")
+	}
+
+	var path string
+	for _, env := range envs {
+		if p := os.Getenv(env); p != "" {
+			path = p
+			break
+		}
+	}
+	return c.envSpecificPath(path)
+}
+
+// envSpecificPath returns the path to the config file specific to the
+// environment in the variable EnvName, if it exists. Otherwise the path is
+// returned unchanged.
+func (c Config) envSpecificPath(path string) string {
+	if c.EnvName == "" || path == "" || path == stdinConfigPath {
+		return path
+	}
+	env := os.Getenv(c.EnvName)
+	if env == "" {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+	envPath := strings.TrimSuffix(path, ext) + "." + env + ext
+	if _, err := os.Stat(envPath); err != nil {
+		return path
+	}
+	return envPath
 }
 
 // setDefaults sets the default values for the configuration. slices and maps are not supported.
-func setDefaults(v *viper.Viper, defaults interface{}) {
+// If mapstructureKeys is true, the mapstructure tags of the fields are used as
+// keys (see WithMapstructureConfigKeys), otherwise the long and short tags.
+func setDefaults(v *viper.Viper, defaults interface{}, mapstructureKeys bool) {
+	setDefaultsRecursive(v, defaults, mapstructureKeys, "")
+}
+
+func setDefaultsRecursive(v *viper.Viper, defaults interface{}, mapstructureKeys bool, keyPrefix string) {
 	val := reflect.ValueOf(defaults)
 	typ := reflect.TypeOf(defaults)
 
@@ -58,16 +212,26 @@ func setDefaults(v *viper.Viper, defaults interface{}) {
 			fieldName = fieldType.Tag.Get("short")
 		}
 
+		prefix := keyPrefix
+		if mapstructureKeys {
+			// the mapstructure tag is the key used when unmarshalling, nested
+			// structs are keyed by the joined tags like in BuildFlags
+			if key, ok := mapstructureKey(fieldType, keyPrefix); ok {
+				fieldName = key
+				prefix = key
+			}
+		}
+
 		if fieldName == "" {
 			continue
 		}
 
 		switch field.Kind() { //nolint:exhaustive // no need to handle all cases
 		case reflect.Struct:
-			setDefaults(v, field.Interface())
+			setDefaultsRecursive(v, field.Interface(), mapstructureKeys, prefix)
 		case reflect.Ptr:
 			if !field.IsNil() {
-				setDefaults(v, field.Interface())
+				setDefaultsRecursive(v, field.Interface(), mapstructureKeys, prefix)
 			}
 		default:
 			if field.CanInterface() {
@@ -77,6 +241,30 @@ func setDefaults(v *viper.Viper, defaults interface{}) {
 	}
 }
 
+// setEmbeddedDefaults reads the embedded config file and sets its values as
+// defaults. It does nothing if no embedded config is configured.
+func setEmbeddedDefaults(v *viper.Viper, cfg EmbeddedConfig) error {
+	if cfg.FS == nil {
+		return nil
+	}
+
+	data, err := fs.ReadFile(cfg.FS, cfg.Path)
+	if err != nil {
+		return fmt.Errorf("could not read %q: %w", cfg.Path, err)
+	}
+
+	embedded := viper.New()
+	embedded.SetConfigType(strings.TrimPrefix(path.Ext(cfg.Path), "."))
+	if err := embedded.ReadConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("could not parse %q: %w", cfg.Path, err)
+	}
+
+	for _, key := range embedded.AllKeys() {
+		v.SetDefault(key, embedded.Get(key))
+	}
+	return nil
+}
+
 // parseConfig parses the configuration (from cfg and cmd) into the viper instance.
 func parseConfig(v *viper.Viper, cfg Config, cmd *cobra.Command) error {
 	// Handle env variables
@@ -85,11 +273,27 @@ func parseConfig(v *viper.Viper, cfg Config, cmd *cobra.Command) error {
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
 	// Handle config file
-	v.SetConfigFile(cfg.Path)
-	if err := v.ReadInConfig(); err != nil {
-		// we make the existence of the config file optional
-		if !os.IsNotExist(err) {
-			return fmt.Errorf("fatal error config file: %w", err)
+	if cfg.Type != "" {
+		v.SetConfigType(cfg.Type)
+	}
+	if configPath := cfg.configPath(); configPath == stdinConfigPath {
+		if cfg.Type == "" {
+			v.SetConfigType("yaml")
+		}
+		r, err := readStdinConfig(cmd)
+		if err != nil {
+			return fmt.Errorf("fatal error config from stdin: %w", err)
+		}
+		if err := v.ReadConfig(r); err != nil {
+			return fmt.Errorf("fatal error config from stdin: %w", err)
+		}
+	} else {
+		v.SetConfigFile(configPath)
+		if err := v.ReadInConfig(); err != nil {
+			// we make the existence of the config file optional
+			if !os.IsNotExist(err) {
+				return fmt.Errorf("fatal error config file: %w", err)
+			}
 		}
 	}
 
@@ -97,7 +301,11 @@ func parseConfig(v *viper.Viper, cfg Config, cmd *cobra.Command) error {
 
 	// Handle flags
 	cmd.Flags().VisitAll(func(f *pflag.Flag) {
-		if err := v.BindPFlag(f.Name, f); err != nil {
+		key := f.Name
+		if keys := f.Annotations[configKeyAnnotation]; len(keys) > 0 {
+			key = keys[0]
+		}
+		if err := v.BindPFlag(key, f); err != nil {
 			errors = append(errors, err)
 		}
 	})
@@ -111,3 +319,224 @@ func parseConfig(v *viper.Viper, cfg Config, cmd *cobra.Command) error {
 	}
 	return nil
 }
+
+// checkMutuallyExclusive returns an error if more than one key in any of the
+// groups is set. It needs to be called before the default values are set in
+// the viper instance, otherwise all keys with defaults are reported as set.
+func checkMutuallyExclusive(v *viper.Viper, groups [][]string) error {
+	for _, group := range groups {
+		var set []string
+		for _, key := range group {
+			if v.IsSet(key) {
+				set = append(set, fmt.Sprintf("%q", key))
+			}
+		}
+		if len(set) > 1 {
+			return fmt.Errorf("%w: config keys %s are mutually exclusive, only one can be set", ErrValidation, strings.Join(set, ", "))
+		}
+	}
+	return nil
+}
+
+// ConfigDiff returns the configuration values that were explicitly set (in the
+// config file, environment variables or flags of cmd) and differ from the
+// default values (DefaultValues and EmbeddedDefault). Keys in the returned map
+// are dotted paths (e.g. "db.host"). This is useful to show users which
+// configuration they changed.
+func ConfigDiff(cfg Config, cmd *cobra.Command) (map[string]any, error) {
+	explicit := viper.New()
+	if err := parseConfig(explicit, cfg, cmd); err != nil {
+		return nil, fmt.Errorf("error parsing config: %w", err)
+	}
+
+	defaults := viper.New()
+	setDefaults(defaults, cfg.DefaultValues, cfg.MapstructureKeys)
+	if err := setEmbeddedDefaults(defaults, cfg.EmbeddedDefault); err != nil {
+		return nil, fmt.Errorf("error parsing embedded default config: %w", err)
+	}
+
+	keys := append(explicit.AllKeys(), defaults.AllKeys()...)
+	diff := make(map[string]any)
+	for _, key := range keys {
+		if _, ok := diff[key]; ok || !explicit.IsSet(key) {
+			continue
+		}
+
+		def := defaults.Get(key)
+		if def == nil {
+			// keys without a configured default fall back to the flag default
+			if f := cmd.Flags().Lookup(key); f != nil {
+				def = f.DefValue
+			}
+		}
+
+		val := explicit.Get(key)
+		if fmt.Sprint(val) != fmt.Sprint(def) {
+			diff[key] = val
+		}
+	}
+	return diff, nil
+}
+
+// RenderConfigDiff writes the diff returned by ConfigDiff to w in the format
+// OutputFormatJSON or OutputFormatYAML.
+func RenderConfigDiff(w io.Writer, diff map[string]any, format OutputFormat) error {
+	switch format {
+	case OutputFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff) //nolint:wrapcheck // no additional context to add
+	case OutputFormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(diff); err != nil {
+			return err //nolint:wrapcheck // no additional context to add
+		}
+		return enc.Close() //nolint:wrapcheck // no additional context to add
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// configKeys returns the dotted paths of all values in the configuration struct,
+// as used by viper when unmarshalling it, mapped to the types of the values.
+// The name of a field is taken from its mapstructure tag, falling back to the
+// field name. Squashed structs don't add a path segment.
+func configKeys(t reflect.Type, prefix string) map[string]reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	keys := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		key := strings.ToLower(name)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft.Kind() == reflect.Struct && (opts == "squash" || field.Anonymous && field.Tag.Get("mapstructure") == ""):
+			maps.Copy(keys, configKeys(ft, prefix))
+		case ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}):
+			maps.Copy(keys, configKeys(ft, key))
+		default:
+			keys[key] = ft
+		}
+	}
+	return keys
+}
+
+// applyConfigOverrides parses overrides in the format key=value and sets them
+// in the viper instance, giving them the highest precedence. Keys need to be
+// one of the known keys (matched case-insensitively), values are coerced to the
+// type of the key.
+func applyConfigOverrides(v *viper.Viper, overrides []string, known map[string]reflect.Type) error {
+	for _, o := range overrides {
+		key, raw, ok := strings.Cut(o, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return fmt.Errorf("invalid value %q, expected key=value", o)
+		}
+		t, ok := known[key]
+		if !ok {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		val, err := coerceConfigValue(raw, t)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for config key %q: expected %s", raw, key, t)
+		}
+		v.Set(key, val)
+	}
+	return nil
+}
+
+// coerceConfigValue parses the raw value into a value of type t. Slices are
+// parsed as comma separated lists. Values of types that can't be parsed from a
+// string (e.g. maps) are returned as is and left to the unmarshalling.
+func coerceConfigValue(raw string, t reflect.Type) (any, error) {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return time.ParseDuration(raw)
+	}
+
+	//nolint:exhaustive // other kinds are left to the unmarshalling
+	switch t.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(i).Convert(t).Interface(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(u).Convert(t).Interface(), nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(f).Convert(t).Interface(), nil
+	case reflect.Slice:
+		if raw == "" {
+			return reflect.MakeSlice(t, 0, 0).Interface(), nil
+		}
+		elems := strings.Split(raw, ",")
+		slice := reflect.MakeSlice(t, len(elems), len(elems))
+		for i, elem := range elems {
+			val, err := coerceConfigValue(strings.TrimSpace(elem), t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			// named types (e.g. type Mode string) are returned as their
+			// underlying type, other elements (e.g. structs) can't be parsed
+			rv := reflect.ValueOf(val)
+			if !rv.Type().ConvertibleTo(t.Elem()) {
+				return nil, fmt.Errorf("unsupported slice element type %s", t.Elem())
+			}
+			slice.Index(i).Set(rv.Convert(t.Elem()))
+		}
+		return slice.Interface(), nil
+	default:
+		return raw, nil
+	}
+}
+
+// mergeInlineConfig parses the JSON or YAML document and sets all contained
+// values as overrides, giving them the highest precedence.
+func mergeInlineConfig(v *viper.Viper, doc string) error {
+	inline := viper.New()
+	inline.SetConfigType("yaml") // YAML is a superset of JSON
+	if err := inline.ReadConfig(strings.NewReader(doc)); err != nil {
+		return fmt.Errorf("invalid document: %w", err)
+	}
+
+	for _, key := range inline.AllKeys() {
+		v.Set(key, inline.Get(key))
+	}
+	return nil
+}
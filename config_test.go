@@ -0,0 +1,906 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type testConfig struct {
+	HeatLevel int    `long:"heat-level" mapstructure:"heat-level"`
+	Name      string `long:"name" mapstructure:"name"`
+}
+
+type testCmdWithConfig struct {
+	cfg               testConfig
+	path              string
+	configType        string
+	configPathEnv     string
+	envName           string
+	mutuallyExclusive [][]string
+	embeddedDefault   EmbeddedConfig
+}
+
+var (
+	_ CommandWithConfig  = (*testCmdWithConfig)(nil)
+	_ CommandWithExecute = (*testCmdWithConfig)(nil)
+)
+
+func (c *testCmdWithConfig) Usage() string { return "cook" }
+func (c *testCmdWithConfig) Config() Config {
+	return Config{
+		EnvPrefix:         "ECDYSIS_TEST",
+		Parsed:            &c.cfg,
+		DefaultValues:     testConfig{HeatLevel: 1, Name: "default"},
+		Path:              c.path,
+		Type:              c.configType,
+		ConfigPathEnv:     c.configPathEnv,
+		EnvName:           c.envName,
+		MutuallyExclusive: c.mutuallyExclusive,
+		EmbeddedDefault:   c.embeddedDefault,
+	}
+}
+func (c *testCmdWithConfig) Execute(context.Context) error { return nil }
+
+// writeConfigFile writes the content to a config file in a temporary directory
+// and returns its path.
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestCommandWithConfigDecorator_Inline(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "heat-level: 5\nname: from-file\n")
+
+	e := New(WithDecorators(CommandWithConfigDecorator{InlineFlag: "config-inline"}))
+	c := &testCmdWithConfig{path: path}
+	cmd := e.MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{"--config-inline", `{"heat-level":9}`})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := testConfig{HeatLevel: 9, Name: "from-file"}
+	if diff := cmp.Diff(want, c.cfg); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestCommandWithConfigDecorator_InlineInvalid(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "heat-level: 5\n")
+
+	e := New(WithDecorators(CommandWithConfigDecorator{InlineFlag: "config-inline"}))
+	cmd := e.MustBuildCobraCommand(&testCmdWithConfig{path: path})
+	cmd.SetArgs([]string{"--config-inline", `{"heat-level":`})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "error parsing inline config") {
+		t.Fatalf("expected inline config error, got %v", err)
+	}
+}
+
+func TestConfig_EnvName(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	files := map[string]string{
+		"config.yaml":         "name: base\n",
+		"config.staging.yaml": "name: staging\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name string
+		env  string
+		want string
+	}{
+		{name: "no env", env: "", want: "base"},
+		{name: "env file exists", env: "staging", want: "staging"},
+		{name: "env file missing", env: "prod", want: "base"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ECDYSIS_TEST_ENV", tc.env)
+
+			c := &testCmdWithConfig{path: path, envName: "ECDYSIS_TEST_ENV"}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs([]string{})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if c.cfg.Name != tc.want {
+				t.Fatalf("expected name %q, got %q", tc.want, c.cfg.Name)
+			}
+		})
+	}
+}
+
+func TestConfig_Stdin(t *testing.T) {
+	testCases := []struct {
+		name       string
+		stdin      string
+		configType string
+		env        map[string]string
+		want       testConfig
+		wantErr    string
+	}{{
+		name:  "yaml",
+		stdin: "heat-level: 5\nname: from-stdin\n",
+		want:  testConfig{HeatLevel: 5, Name: "from-stdin"},
+	}, {
+		name:       "json",
+		stdin:      `{"name": "from-stdin"}`,
+		configType: "json",
+		want:       testConfig{HeatLevel: 1, Name: "from-stdin"},
+	}, {
+		name:  "env overrides stdin",
+		stdin: "heat-level: 5\nname: from-stdin\n",
+		env:   map[string]string{"ECDYSIS_TEST_NAME": "from-env"},
+		want:  testConfig{HeatLevel: 5, Name: "from-env"},
+	}, {
+		name:  "empty",
+		stdin: "",
+		want:  testConfig{HeatLevel: 1, Name: "default"},
+	}, {
+		name:    "invalid",
+		stdin:   "heat-level: [",
+		wantErr: "fatal error config from stdin",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			c := &testCmdWithConfig{path: "-", configType: tc.configType}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs([]string{})
+			cmd.SetIn(strings.NewReader(tc.stdin))
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, c.cfg); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestConfig_PathFromEnv(t *testing.T) {
+	filePath := writeConfigFile(t, "config.yaml", "name: from-file\n")
+	envPath := writeConfigFile(t, "env.yaml", "name: from-env\n")
+
+	testCases := []struct {
+		name          string
+		path          string
+		env           map[string]string
+		configPathEnv string
+		want          string
+	}{{
+		name: "no env",
+		path: filePath,
+		want: "from-file",
+	}, {
+		name:          "custom env",
+		env:           map[string]string{"APP_CONFIG": envPath},
+		configPathEnv: "APP_CONFIG",
+		want:          "from-env",
+	}, {
+		name:          "custom env not set",
+		env:           map[string]string{"ECDYSIS_TEST_CONFIG_PATH": envPath},
+		configPathEnv: "APP_CONFIG",
+		want:          "from-env",
+	}, {
+		name: "prefixed env",
+		env:  map[string]string{"ECDYSIS_TEST_CONFIG_PATH": envPath},
+		want: "from-env",
+	}, {
+		name:          "path wins over env",
+		path:          filePath,
+		env:           map[string]string{"APP_CONFIG": envPath, "ECDYSIS_TEST_CONFIG_PATH": envPath},
+		configPathEnv: "APP_CONFIG",
+		want:          "from-file",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			c := &testCmdWithConfig{path: tc.path, configPathEnv: tc.configPathEnv}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs([]string{})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if c.cfg.Name != tc.want {
+				t.Fatalf("expected name %q, got %q", tc.want, c.cfg.Name)
+			}
+		})
+	}
+}
+
+func TestConfig_MutuallyExclusive(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		args    []string
+		wantErr bool
+	}{{
+		name:    "none set",
+		content: "",
+	}, {
+		name:    "one set in file",
+		content: "name: foo\n",
+	}, {
+		name:    "both set in file",
+		content: "heat-level: 5\nname: foo\n",
+		wantErr: true,
+	}, {
+		name:    "one set in file, one as flag",
+		content: "heat-level: 5\n",
+		args:    []string{"--name", "foo"},
+		wantErr: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeConfigFile(t, "config.yaml", tc.content)
+
+			c := &testCmdWithConfig{
+				path:              path,
+				mutuallyExclusive: [][]string{{"heat-level", "name"}},
+			}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.Flags().String("name", "", "name")
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			if tc.wantErr {
+				if !errors.Is(err, ErrValidation) {
+					t.Fatalf("expected validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfig_EmbeddedDefault(t *testing.T) {
+	embedded := EmbeddedConfig{
+		FS: fstest.MapFS{
+			"defaults.yaml": {Data: []byte("heat-level: 3\nname: embedded\n")},
+		},
+		Path: "defaults.yaml",
+	}
+
+	testCases := []struct {
+		name    string
+		content string
+		want    testConfig
+	}{{
+		name: "no user config",
+		want: testConfig{HeatLevel: 3, Name: "embedded"},
+	}, {
+		name:    "user config overrides embedded",
+		content: "name: user\n",
+		want:    testConfig{HeatLevel: 3, Name: "user"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "missing.yaml")
+			if tc.content != "" {
+				path = writeConfigFile(t, "config.yaml", tc.content)
+			}
+
+			c := &testCmdWithConfig{path: path, embeddedDefault: embedded}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs([]string{})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want, c.cfg); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestConfigDiff(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "heat-level: 1\nname: custom\n")
+	c := &testCmdWithConfig{path: path}
+	cmd := New().MustBuildCobraCommand(c)
+	cmd.Flags().Bool("verbose", false, "verbose output")
+
+	diff, err := ConfigDiff(c.Config(), cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// heat-level is set to the default value, so it's not part of the diff
+	want := map[string]any{"name": "custom"}
+	if d := cmp.Diff(want, diff); d != "" {
+		t.Fatal(d)
+	}
+
+	cmd.Flags().Int("heat-level", 0, "heat level")
+	if err := cmd.ParseFlags([]string{"--heat-level", "7"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	diff, err = ConfigDiff(c.Config(), cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = map[string]any{"heat-level": 7, "name": "custom"}
+	if d := cmp.Diff(want, diff); d != "" {
+		t.Fatal(d)
+	}
+}
+
+func TestConfigDiff_Stdin(t *testing.T) {
+	c := &testCmdWithConfig{path: "-"}
+	cmd := New().MustBuildCobraCommand(c)
+	cmd.SetIn(strings.NewReader("name: from-stdin\n"))
+	cmd.SetArgs([]string{})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.cfg.Name != "from-stdin" {
+		t.Fatalf("expected name %q, got %q", "from-stdin", c.cfg.Name)
+	}
+
+	// the standard input was consumed by the execution
+	diff, err := ConfigDiff(c.Config(), cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]any{"name": "from-stdin"}
+	if d := cmp.Diff(want, diff); d != "" {
+		t.Fatal(d)
+	}
+}
+
+func TestConfig_StdinExecutedTwice(t *testing.T) {
+	c := &testCmdWithConfig{path: "-"}
+	cmd := New().MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{})
+
+	for _, name := range []string{"first", "second"} {
+		cmd.SetIn(strings.NewReader("name: " + name + "\n"))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if c.cfg.Name != name {
+			t.Fatalf("expected name %q, got %q", name, c.cfg.Name)
+		}
+	}
+}
+
+func TestRenderConfigDiff(t *testing.T) {
+	diff := map[string]any{"heat-level": 7, "name": "custom"}
+
+	testCases := []struct {
+		format OutputFormat
+		want   string
+	}{{
+		format: OutputFormatJSON,
+		want:   "{\n  \"heat-level\": 7,\n  \"name\": \"custom\"\n}\n",
+	}, {
+		format: OutputFormatYAML,
+		want:   "heat-level: 7\nname: custom\n",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.format), func(t *testing.T) {
+			var sb strings.Builder
+			if err := RenderConfigDiff(&sb, diff, tc.format); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if d := cmp.Diff(tc.want, sb.String()); d != "" {
+				t.Fatal(d)
+			}
+		})
+	}
+
+	if err := RenderConfigDiff(io.Discard, diff, OutputFormatText); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
+
+type testNestedConfig struct {
+	Name     string `mapstructure:"name"`
+	Database struct {
+		Host string `mapstructure:"host"`
+		Port int    `mapstructure:"port"`
+	} `mapstructure:"database"`
+}
+
+type testCmdWithNestedConfig struct {
+	cfg  testNestedConfig
+	path string
+}
+
+var (
+	_ CommandWithConfig  = (*testCmdWithNestedConfig)(nil)
+	_ CommandWithExecute = (*testCmdWithNestedConfig)(nil)
+)
+
+func (c *testCmdWithNestedConfig) Usage() string { return "serve" }
+func (c *testCmdWithNestedConfig) Config() Config {
+	return Config{
+		EnvPrefix:     "ECDYSIS_TEST",
+		Parsed:        &c.cfg,
+		DefaultValues: testNestedConfig{},
+		Path:          c.path,
+	}
+}
+func (c *testCmdWithNestedConfig) Execute(context.Context) error { return nil }
+
+func TestCommandWithConfigDecorator_Set(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "name: from-file\ndatabase:\n  host: localhost\n  port: 5432\n")
+
+	e := New(WithDecorators(CommandWithConfigDecorator{SetFlag: "set"}))
+	c := &testCmdWithNestedConfig{path: path}
+	cmd := e.MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{"--set", "database.host=db1", "--set", "Database.Port=6543"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := testNestedConfig{Name: "from-file"}
+	want.Database.Host = "db1"
+	want.Database.Port = 6543
+	if diff := cmp.Diff(want, c.cfg); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestCommandWithConfigDecorator_SetInvalid(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "name: from-file\n")
+
+	testCases := []struct {
+		name    string
+		set     string
+		wantErr string
+	}{{
+		name:    "unknown path",
+		set:     "database.user=admin",
+		wantErr: `invalid flag --set: unknown config key "database.user"`,
+	}, {
+		name:    "path to struct",
+		set:     "database=db1",
+		wantErr: `invalid flag --set: unknown config key "database"`,
+	}, {
+		name:    "missing value",
+		set:     "database.host",
+		wantErr: `invalid flag --set: invalid value "database.host", expected key=value`,
+	}, {
+		name:    "type mismatch",
+		set:     "database.port=abc",
+		wantErr: `invalid flag --set: invalid value "abc" for config key "database.port": expected int`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := New(WithDecorators(CommandWithConfigDecorator{SetFlag: "set"}))
+			cmd := e.MustBuildCobraCommand(&testCmdWithNestedConfig{path: path})
+			cmd.SetArgs([]string{"--set", tc.set})
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+type testNamedString string
+
+func TestCoerceConfigValue(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     string
+		typ     reflect.Type
+		want    any
+		wantErr bool
+	}{
+		{name: "string", raw: "db1", typ: reflect.TypeOf(""), want: "db1"},
+		{name: "bool", raw: "true", typ: reflect.TypeOf(false), want: true},
+		{name: "int8", raw: "-8", typ: reflect.TypeOf(int8(0)), want: int8(-8)},
+		{name: "uint", raw: "8", typ: reflect.TypeOf(uint(0)), want: uint(8)},
+		{name: "float32", raw: "1.5", typ: reflect.TypeOf(float32(0)), want: float32(1.5)},
+		{name: "duration", raw: "5s", typ: reflect.TypeOf(time.Duration(0)), want: 5 * time.Second},
+		{name: "slice", raw: "1, 2,3", typ: reflect.TypeOf([]int{}), want: []int{1, 2, 3}},
+		{name: "map", raw: "a", typ: reflect.TypeOf(map[string]string{}), want: "a"},
+		{name: "invalid bool", raw: "maybe", typ: reflect.TypeOf(false), wantErr: true},
+		{name: "int overflow", raw: "300", typ: reflect.TypeOf(int8(0)), wantErr: true},
+		{name: "invalid slice element", raw: "1,x", typ: reflect.TypeOf([]int{}), wantErr: true},
+		{name: "named string slice", raw: "a,b", typ: reflect.TypeOf([]testNamedString{}), want: []testNamedString{"a", "b"}},
+		{name: "unsupported slice element", raw: "a,b", typ: reflect.TypeOf([]struct{ Name string }{}), wantErr: true},
+		{name: "pointer slice element", raw: "a", typ: reflect.TypeOf([]*string{}), wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := coerceConfigValue(tc.raw, tc.typ)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+type testMapstructureConfig struct {
+	Level int `long:"heat" mapstructure:"heat-level"`
+}
+
+type testCmdWithMapstructureKeys struct {
+	flags testMapstructureConfig
+	cfg   testMapstructureConfig
+	path  string
+}
+
+var (
+	_ CommandWithConfig  = (*testCmdWithMapstructureKeys)(nil)
+	_ CommandWithFlags   = (*testCmdWithMapstructureKeys)(nil)
+	_ CommandWithExecute = (*testCmdWithMapstructureKeys)(nil)
+)
+
+func (c *testCmdWithMapstructureKeys) Usage() string { return "cook" }
+func (c *testCmdWithMapstructureKeys) Flags() []Flag {
+	return BuildFlags(&c.flags, WithMapstructureConfigKeys())
+}
+func (c *testCmdWithMapstructureKeys) Config() Config {
+	return Config{
+		Parsed:           &c.cfg,
+		DefaultValues:    testMapstructureConfig{Level: 1},
+		Path:             c.path,
+		MapstructureKeys: true,
+	}
+}
+func (c *testCmdWithMapstructureKeys) Execute(context.Context) error { return nil }
+
+func TestBuildFlags_MapstructureConfigKeys(t *testing.T) {
+	var nested struct {
+		Name     string `long:"name" mapstructure:"name"`
+		Database struct {
+			Host string `long:"db-host" mapstructure:"host"`
+			Port int    `long:"db-port"`
+		} `mapstructure:"database"`
+	}
+
+	var keys []string
+	for _, f := range BuildFlags(&nested, WithMapstructureConfigKeys()) {
+		keys = append(keys, f.ConfigKey)
+	}
+	if diff := cmp.Diff([]string{"name", "database.host", ""}, keys); diff != "" {
+		t.Fatal(diff)
+	}
+
+	for _, f := range BuildFlags(&nested) {
+		if f.ConfigKey != "" {
+			t.Fatalf("expected no config key without option, got %q", f.ConfigKey)
+		}
+	}
+}
+
+func TestConfig_MapstructureConfigKeys(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		args    []string
+		want    int
+	}{
+		{name: "default", want: 1},
+		{name: "config file", content: "heat-level: 3\n", want: 3},
+		{name: "flag", content: "heat-level: 3\n", args: []string{"--heat", "5"}, want: 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &testCmdWithMapstructureKeys{path: writeConfigFile(t, "config.yaml", tc.content)}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs(tc.args)
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if c.cfg.Level != tc.want {
+				t.Fatalf("expected level %d, got %d", tc.want, c.cfg.Level)
+			}
+		})
+	}
+}
+
+type testCmdWithConfigFromContext struct {
+	testCmdWithConfig
+	fromCtx *testConfig
+}
+
+func (c *testCmdWithConfigFromContext) Execute(ctx context.Context) error {
+	c.fromCtx = heatLevelHelper(ctx)
+	return nil
+}
+
+// heatLevelHelper simulates a helper deep in the call stack that needs the
+// configuration without it being passed as an argument.
+func heatLevelHelper(ctx context.Context) *testConfig {
+	cfg, _ := ConfigFromContext(ctx).(*testConfig)
+	return cfg
+}
+
+func TestConfigFromContext(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "heat-level: 5\n")
+
+	c := &testCmdWithConfigFromContext{testCmdWithConfig: testCmdWithConfig{path: path}}
+	cmd := New().MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if c.fromCtx != &c.cfg {
+		t.Fatalf("expected context to contain the parsed config %p, got %p", &c.cfg, c.fromCtx)
+	}
+	if c.fromCtx.HeatLevel != 5 {
+		t.Fatalf("expected heat level 5, got %d", c.fromCtx.HeatLevel)
+	}
+}
+
+func TestConfigFromContext_Missing(t *testing.T) {
+	if cfg := ConfigFromContext(context.Background()); cfg != nil {
+		t.Fatalf("expected no config, got %v", cfg)
+	}
+}
+
+type testCmdWithConfigReload struct {
+	testCmdWithConfig
+	reloaded chan struct{}
+	execute  func(ctx context.Context) error
+}
+
+var _ CommandWithConfigReload = (*testCmdWithConfigReload)(nil)
+
+func (c *testCmdWithConfigReload) OnConfigReload(context.Context) { c.reloaded <- struct{}{} }
+func (c *testCmdWithConfigReload) Execute(ctx context.Context) error {
+	return c.execute(ctx)
+}
+
+func TestConfigReloadDecorator(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "heat-level: 5\nname: before\n")
+
+	var signals chan<- os.Signal
+	failed := make(chan string)
+	e := New(
+		WithDecorators(ConfigReloadDecorator{
+			Notify: func(c chan<- os.Signal) func() {
+				signals = c
+				return func() {}
+			},
+		}),
+		WithOutput(func(cmd *cobra.Command) Output {
+			return NewTeeOutput(cmd, writerFunc(func(p []byte) (int, error) {
+				failed <- string(p)
+				return len(p), nil
+			}))
+		}),
+	)
+
+	cmd := &testCmdWithConfigReload{
+		testCmdWithConfig: testCmdWithConfig{path: path},
+		reloaded:          make(chan struct{}),
+	}
+	readConfig := func(ctx context.Context) testConfig {
+		unlock := RLockConfig(ctx)
+		defer unlock()
+		return cmd.cfg
+	}
+	reload := func(content string) error {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			return err
+		}
+		signals <- syscall.SIGHUP
+		return nil
+	}
+	cmd.execute = func(ctx context.Context) error {
+		if diff := cmp.Diff(testConfig{HeatLevel: 5, Name: "before"}, readConfig(ctx)); diff != "" {
+			return errors.New(diff)
+		}
+		before := ConfigFromContext(ctx)
+
+		// an invalid configuration is reported and the previous one is kept
+		if err := reload("heat-level: hot\n"); err != nil {
+			return err
+		}
+		select {
+		case msg := <-failed:
+			if !strings.HasPrefix(msg, "failed to reload configuration: ") {
+				return fmt.Errorf("unexpected message %q", msg)
+			}
+		case <-cmd.reloaded:
+			return errors.New("expected reload to fail")
+		case <-time.After(5 * time.Second):
+			return errors.New("reload error was not reported")
+		}
+		if diff := cmp.Diff(testConfig{HeatLevel: 5, Name: "before"}, readConfig(ctx)); diff != "" {
+			return errors.New(diff)
+		}
+
+		if err := reload("heat-level: 7\nname: after\n"); err != nil {
+			return err
+		}
+		select {
+		case <-cmd.reloaded:
+		case <-time.After(5 * time.Second):
+			return errors.New("configuration was not reloaded")
+		}
+
+		if diff := cmp.Diff(testConfig{HeatLevel: 7, Name: "after"}, readConfig(ctx)); diff != "" {
+			return errors.New(diff)
+		}
+		if diff := cmp.Diff(&testConfig{HeatLevel: 7, Name: "after"}, ConfigFromContext(ctx)); diff != "" {
+			return fmt.Errorf("expected context to contain the reloaded configuration: %s", diff)
+		}
+		// values fetched before the reload are not modified
+		if diff := cmp.Diff(&testConfig{HeatLevel: 5, Name: "before"}, before); diff != "" {
+			return errors.New(diff)
+		}
+		return nil
+	}
+
+	c := e.MustBuildCobraCommand(cmd)
+	var stderr strings.Builder
+	c.SetArgs([]string{})
+	c.SetOut(io.Discard)
+	c.SetErr(&stderr)
+
+	if err := c.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := stderr.String(); !strings.HasPrefix(got, "failed to reload configuration: ") {
+		t.Fatalf("expected reload error to be reported, got %q", got)
+	}
+}
+
+func TestSetDefaults_MapstructureKeys(t *testing.T) {
+	type database struct {
+		Host string `long:"db-host" mapstructure:"host"`
+		Port int    `long:"db-port"`
+	}
+	defaults := struct {
+		Name     string   `long:"name" mapstructure:"app-name"`
+		Database database `long:"db" mapstructure:"database"`
+	}{
+		Name:     "app",
+		Database: database{Host: "localhost", Port: 5432},
+	}
+
+	testCases := []struct {
+		name             string
+		mapstructureKeys bool
+		want             map[string]any
+	}{{
+		name: "long names",
+		want: map[string]any{"name": "app", "db-host": "localhost", "db-port": 5432},
+	}, {
+		name:             "mapstructure keys",
+		mapstructureKeys: true,
+		want:             map[string]any{"app-name": "app", "database.host": "localhost", "db-port": 5432},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := viper.New()
+			setDefaults(v, defaults, tc.mapstructureKeys)
+
+			got := make(map[string]any)
+			for _, key := range v.AllKeys() {
+				got[key] = v.Get(key)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestConfigReloadDecorator_Stdin(t *testing.T) {
+	var notified bool
+	e := New(WithDecorators(ConfigReloadDecorator{
+		Notify: func(chan<- os.Signal) func() {
+			notified = true
+			return func() {}
+		},
+	}))
+
+	cmd := &testCmdWithConfigReload{
+		testCmdWithConfig: testCmdWithConfig{path: "-"},
+		execute:           func(context.Context) error { return nil },
+	}
+	c := e.MustBuildCobraCommand(cmd)
+	c.SetArgs([]string{})
+	c.SetIn(strings.NewReader("heat-level: 5\n"))
+
+	if err := c.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if notified {
+		t.Fatal("expected configuration read from stdin not to be reloaded")
+	}
+	if cmd.cfg.HeatLevel != 5 {
+		t.Fatalf("expected heat level 5, got %d", cmd.cfg.HeatLevel)
+	}
+}
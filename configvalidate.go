@@ -0,0 +1,211 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ConfigValidationResult is the result of validating a config file using the
+// command returned by NewConfigValidateCommand.
+type ConfigValidationResult struct {
+	// Path is the path to the validated config file.
+	Path string `json:"path"`
+	// Valid is true if the config file doesn't contain any problems.
+	Valid bool `json:"valid"`
+	// Errors contains the problems found in the config file.
+	Errors []ConfigFieldError `json:"errors"`
+}
+
+// ConfigFieldError is a problem found in a config file.
+type ConfigFieldError struct {
+	// Field is the dotted path to the value in the config file (e.g.
+	// "database.port").
+	Field string `json:"field"`
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+// NewConfigValidateCommand returns a command that validates a config file. The
+// file is read from the path in the first argument, falling back to the path
+// in the config (including the path environment variables). Every value is
+// decoded into the type of its field in Config.Parsed, using the same decode
+// hooks as when the config is parsed, and keys that don't exist in the config
+// struct are reported. With --output json the result is printed as a
+// ConfigValidationResult, which is useful in CI. If the config file contains
+// problems, the command returns an error wrapping ErrValidation.
+func NewConfigValidateCommand(cfg Config) Command {
+	return &configValidateCommand{cfg: cfg}
+}
+
+type configValidateCommand struct {
+	cfg    Config
+	path   string
+	format string
+	output Output
+}
+
+var (
+	_ CommandWithArgs    = (*configValidateCommand)(nil)
+	_ CommandWithDocs    = (*configValidateCommand)(nil)
+	_ CommandWithExecute = (*configValidateCommand)(nil)
+	_ CommandWithFlags   = (*configValidateCommand)(nil)
+	_ CommandWithOutput  = (*configValidateCommand)(nil)
+)
+
+func (c *configValidateCommand) Usage() string { return "validate [PATH]" }
+
+func (c *configValidateCommand) Docs() Docs {
+	return Docs{
+		Short: "Validate a configuration file",
+		Long: `Validate a configuration file. Values of the wrong type and unknown keys are
+reported together with the path to the field.`,
+	}
+}
+
+func (c *configValidateCommand) Flags() []Flag {
+	return []Flag{{
+		Long:    outputFlagName,
+		Usage:   "output format (text, json)",
+		Default: string(OutputFormatText),
+		Ptr:     &c.format,
+	}}
+}
+
+func (c *configValidateCommand) Args(args []string) error {
+	switch len(args) {
+	case 0:
+		c.path = c.cfg.configPath()
+	case 1:
+		c.path = args[0]
+	default:
+		return fmt.Errorf("expected at most 1 argument, got %d", len(args))
+	}
+	return nil
+}
+
+func (c *configValidateCommand) Output(output Output) { c.output = output }
+
+func (c *configValidateCommand) Execute(ctx context.Context) error {
+	result, err := c.validate(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch OutputFormat(strings.ToLower(c.format)) {
+	case OutputFormatJSON:
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode result: %w", err)
+		}
+		c.output.Stdout(string(out) + "\n")
+	case OutputFormatText:
+		if result.Valid {
+			c.output.Stdout(fmt.Sprintf("Config file %q is valid.\n", result.Path))
+		}
+		for _, e := range result.Errors {
+			c.output.Stderr(fmt.Sprintf("%s: %s\n", e.Field, e.Message))
+		}
+	default:
+		return fmt.Errorf("unsupported output format %q", c.format)
+	}
+
+	if !result.Valid {
+		// the problems were reported, the usage would only add noise
+		CobraCmdFromContext(ctx).SilenceUsage = true
+		return fmt.Errorf("%w: config file %q contains %d problem(s)", ErrValidation, result.Path, len(result.Errors))
+	}
+	return nil
+}
+
+// validate reads the config file and checks every value in it.
+func (c *configValidateCommand) validate(ctx context.Context) (ConfigValidationResult, error) {
+	parsedType := reflect.TypeOf(c.cfg.Parsed)
+	if parsedType == nil || parsedType.Kind() != reflect.Ptr {
+		return ConfigValidationResult{}, fmt.Errorf("parsed must be a pointer")
+	}
+	if c.path == "" {
+		return ConfigValidationResult{}, fmt.Errorf("no config file to validate")
+	}
+
+	v := viper.New()
+	if c.cfg.Type != "" {
+		v.SetConfigType(c.cfg.Type)
+	}
+	if c.path == stdinConfigPath {
+		if c.cfg.Type == "" {
+			v.SetConfigType("yaml")
+		}
+		in := CobraCmdFromContext(ctx).InOrStdin()
+		if err := v.ReadConfig(in); err != nil {
+			return ConfigValidationResult{}, fmt.Errorf("could not read config from stdin: %w", err)
+		}
+	} else {
+		v.SetConfigFile(c.path)
+		if err := v.ReadInConfig(); err != nil {
+			return ConfigValidationResult{}, fmt.Errorf("could not read config file: %w", err)
+		}
+	}
+
+	known := configKeys(parsedType.Elem(), "")
+	result := ConfigValidationResult{Path: c.path, Errors: []ConfigFieldError{}}
+	for _, key := range v.AllKeys() {
+		t, ok := lookupConfigKey(known, key)
+		if !ok {
+			result.Errors = append(result.Errors, ConfigFieldError{Field: key, Message: "unknown key"})
+			continue
+		}
+		// decoding the key uses the same decode hooks as viper.Unmarshal
+		if err := v.UnmarshalKey(key, reflect.New(t).Interface()); err != nil {
+			result.Errors = append(result.Errors, ConfigFieldError{
+				Field:   key,
+				Message: fmt.Sprintf("invalid value %v, expected %s", v.Get(key), t),
+			})
+		}
+	}
+
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Field < result.Errors[j].Field
+	})
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+// lookupConfigKey returns the type of the key. Keys nested in a map or an
+// interface value are known and have the type of their nearest ancestor.
+func lookupConfigKey(known map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if t, ok := known[key]; ok {
+		return t, true
+	}
+	for prefix := key; strings.Contains(prefix, "."); {
+		prefix = prefix[:strings.LastIndex(prefix, ".")]
+		t, ok := known[prefix]
+		if !ok {
+			continue
+		}
+		if t.Kind() == reflect.Map || t.Kind() == reflect.Interface {
+			return reflect.TypeOf((*any)(nil)).Elem(), true
+		}
+		return nil, false
+	}
+	return nil, false
+}
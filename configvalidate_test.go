@@ -0,0 +1,97 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigValidateCommand(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    ConfigValidationResult
+	}{{
+		name:    "valid",
+		content: "name: pipeline\ndatabase:\n  host: localhost\n  port: 5432\n",
+		want:    ConfigValidationResult{Valid: true, Errors: []ConfigFieldError{}},
+	}, {
+		name:    "invalid",
+		content: "name: pipeline\ndatabase:\n  hots: localhost\n  port: abc\n",
+		want: ConfigValidationResult{
+			Valid: false,
+			Errors: []ConfigFieldError{
+				{Field: "database.hots", Message: "unknown key"},
+				{Field: "database.port", Message: "invalid value abc, expected int"},
+			},
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeConfigFile(t, "config.yaml", tc.content)
+			tc.want.Path = path
+
+			var cfg testNestedConfig
+			c := NewConfigValidateCommand(Config{Parsed: &cfg, DefaultValues: cfg, Path: path})
+			cmd := New().MustBuildCobraCommand(c)
+			var out strings.Builder
+			cmd.SetArgs([]string{"--output", "json"})
+			cmd.SetOut(&out)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			if tc.want.Valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.want.Valid && !errors.Is(err, ErrValidation) {
+				t.Fatalf("expected validation error, got %v", err)
+			}
+
+			var got ConfigValidationResult
+			if err := json.Unmarshal([]byte(out.String()), &got); err != nil {
+				t.Fatalf("failed to decode output %q: %v", out.String(), err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestConfigValidateCommand_Text(t *testing.T) {
+	var cfg testNestedConfig
+	c := NewConfigValidateCommand(Config{Parsed: &cfg, DefaultValues: cfg})
+	cmd := New().MustBuildCobraCommand(c)
+
+	path := writeConfigFile(t, "config.yaml", "database:\n  port: abc\n")
+	var stdout, stderr strings.Builder
+	cmd.SetArgs([]string{path})
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+
+	if err := cmd.Execute(); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if !strings.Contains(stderr.String(), "database.port: invalid value abc, expected int\n") {
+		t.Fatalf("expected field error in output, got %q", stderr.String())
+	}
+}
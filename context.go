@@ -16,6 +16,9 @@ package ecdysis
 
 import (
 	"context"
+	"log/slog"
+	"runtime/debug"
+	"sync"
 
 	"github.com/spf13/cobra"
 )
@@ -36,3 +39,192 @@ func CobraCmdFromContext(ctx context.Context) *cobra.Command {
 	}
 	return nil
 }
+
+type correlationIDCtxKey struct{}
+
+// ContextWithCorrelationID returns a copy of the context containing the
+// correlation ID.
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDCtxKey{}, id)
+}
+
+// CorrelationIDFromContext fetches the correlation ID from the context. If the
+// context does not contain a correlation ID, it returns an empty string.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDCtxKey{}).(string)
+	return id
+}
+
+type configCtxKey struct{}
+
+// ContextWithConfig returns a copy of the context containing the parsed
+// configuration.
+func ContextWithConfig(ctx context.Context, cfg any) context.Context {
+	return context.WithValue(ctx, configCtxKey{}, cfg)
+}
+
+// ConfigFromContext fetches the parsed configuration from the context. For
+// commands implementing CommandWithConfig, it contains the value of
+// Config.Parsed (i.e. a pointer to the configuration struct), which can be
+// retrieved using a type assertion:
+//
+//	cfg, ok := ecdysis.ConfigFromContext(ctx).(*MyConfig)
+//
+// If the configuration is reloaded (see WithConfigReload), it returns a copy
+// of the latest configuration, which is not modified by later reloads and can
+// be read without holding the lock returned by RLockConfig.
+//
+// If the context does not contain a configuration, it returns nil.
+func ConfigFromContext(ctx context.Context) any {
+	if rc, ok := ctx.Value(configCtxKey{}).(*reloadableConfig); ok {
+		rc.mu.RLock()
+		defer rc.mu.RUnlock()
+		return rc.parsed
+	}
+	return ctx.Value(configCtxKey{})
+}
+
+// reloadableConfig holds the parsed configuration of a command reloading its
+// configuration. The lock guards parsed and the value of Config.Parsed.
+type reloadableConfig struct {
+	mu     sync.RWMutex
+	parsed any
+}
+
+// contextWithReloadableConfig returns a copy of the context containing the
+// reloadable configuration.
+func contextWithReloadableConfig(ctx context.Context, rc *reloadableConfig) context.Context {
+	return context.WithValue(ctx, configCtxKey{}, rc)
+}
+
+// RLockConfig locks the parsed configuration for reading and returns the
+// function releasing the lock. Commands reloading their configuration (see
+// WithConfigReload) need to hold the lock while reading Config.Parsed, as it
+// is updated in place, so they don't observe a partially reloaded
+// configuration:
+//
+//	unlock := ecdysis.RLockConfig(ctx)
+//	addr := c.config.Address
+//	unlock()
+//
+// If the configuration is not reloaded, the returned function is a no-op.
+func RLockConfig(ctx context.Context) (unlock func()) {
+	rc, ok := ctx.Value(configCtxKey{}).(*reloadableConfig)
+	if !ok {
+		return func() {}
+	}
+	rc.mu.RLock()
+	return rc.mu.RUnlock
+}
+
+type (
+	loggerCtxKey       struct{}
+	outputFormatCtxKey struct{}
+	configPathCtxKey   struct{}
+	buildInfoCtxKey    struct{}
+)
+
+// contextWithLogger returns a copy of the context containing the logger,
+// unless the context already contains a logger (e.g. seeded using
+// SeedContext).
+func contextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	if _, ok := ctx.Value(loggerCtxKey{}).(*slog.Logger); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerCtxKey{}, logger)
+}
+
+// contextWithConfigPath returns a copy of the context containing the config
+// path, unless the context already contains a config path (e.g. seeded using
+// SeedContext).
+func contextWithConfigPath(ctx context.Context, path string) context.Context {
+	if ConfigPathFromContext(ctx) != "" {
+		return ctx
+	}
+	return context.WithValue(ctx, configPathCtxKey{}, path)
+}
+
+// ContextValues contains values that can be seeded into a context using
+// SeedContext. Zero values are not seeded. When a command is executed, the
+// decorators provide the same values (the logger of CommandWithLoggerDecorator,
+// the config path of CommandWithConfigDecorator, the output format selected
+// using --output and the build info of the binary), seeded values take
+// precedence.
+type ContextValues struct {
+	Logger        *slog.Logger
+	OutputFormat  OutputFormat
+	ConfigPath    string
+	BuildInfo     *debug.BuildInfo
+	CorrelationID string
+}
+
+// SeedContext returns a copy of the context containing all non-zero values in
+// one call. It is meant for tests executing commands that read many context
+// values:
+//
+//	ctx := ecdysis.SeedContext(context.Background(), ecdysis.ContextValues{
+//		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+//		OutputFormat: ecdysis.OutputFormatJSON,
+//	})
+//	err := cmd.ExecuteContext(ctx)
+func SeedContext(ctx context.Context, values ContextValues) context.Context {
+	if values.Logger != nil {
+		ctx = context.WithValue(ctx, loggerCtxKey{}, values.Logger)
+	}
+	if values.OutputFormat != "" {
+		ctx = context.WithValue(ctx, outputFormatCtxKey{}, values.OutputFormat)
+	}
+	if values.ConfigPath != "" {
+		ctx = context.WithValue(ctx, configPathCtxKey{}, values.ConfigPath)
+	}
+	if values.BuildInfo != nil {
+		ctx = context.WithValue(ctx, buildInfoCtxKey{}, values.BuildInfo)
+	}
+	if values.CorrelationID != "" {
+		ctx = ContextWithCorrelationID(ctx, values.CorrelationID)
+	}
+	return ctx
+}
+
+// LoggerFromContext fetches the logger from the context. For commands decorated
+// by CommandWithLoggerDecorator, it contains the logger of the command. If the
+// context does not contain a logger, it returns slog.Default().
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerCtxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// OutputFormatFromContext fetches the output format from the context. If the
+// context does not contain an output format, the format selected using the
+// flag --output of the cobra command in the context is returned, falling back
+// to OutputFormatText.
+func OutputFormatFromContext(ctx context.Context) OutputFormat {
+	if format, ok := ctx.Value(outputFormatCtxKey{}).(OutputFormat); ok {
+		return format
+	}
+	if cmd := CobraCmdFromContext(ctx); cmd != nil {
+		return outputFormatFromCommand(cmd)
+	}
+	return OutputFormatText
+}
+
+// ConfigPathFromContext fetches the config path from the context. For commands
+// implementing CommandWithConfig, it contains the path of the config file
+// (after resolving environment variables). If the context does not contain a
+// config path, it returns an empty string.
+func ConfigPathFromContext(ctx context.Context) string {
+	path, _ := ctx.Value(configPathCtxKey{}).(string)
+	return path
+}
+
+// BuildInfoFromContext fetches the build info from the context. If the context
+// does not contain build info, the build info embedded in the running binary is
+// returned. It returns false if no build info is available.
+func BuildInfoFromContext(ctx context.Context) (*debug.BuildInfo, bool) {
+	if info, ok := ctx.Value(buildInfoCtxKey{}).(*debug.BuildInfo); ok {
+		return info, true
+	}
+	return debug.ReadBuildInfo()
+}
@@ -0,0 +1,116 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"runtime/debug"
+	"testing"
+)
+
+type testCmdWithContext struct {
+	ctx context.Context
+}
+
+var _ CommandWithExecute = (*testCmdWithContext)(nil)
+
+func (c *testCmdWithContext) Usage() string { return "inspect" }
+func (c *testCmdWithContext) Execute(ctx context.Context) error {
+	c.ctx = ctx
+	return nil
+}
+
+func TestSeedContext(t *testing.T) {
+	values := ContextValues{
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		OutputFormat:  OutputFormatJSON,
+		ConfigPath:    "/etc/mycli.yaml",
+		BuildInfo:     &debug.BuildInfo{Main: debug.Module{Path: "example.com/mycli", Version: "v1.2.3"}},
+		CorrelationID: "trace-123",
+	}
+
+	c := &testCmdWithContext{}
+	cmd := New().MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{})
+	if err := cmd.ExecuteContext(SeedContext(context.Background(), values)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := c.ctx
+	if got := LoggerFromContext(ctx); got != values.Logger {
+		t.Fatalf("expected seeded logger, got %v", got)
+	}
+	if got := OutputFormatFromContext(ctx); got != values.OutputFormat {
+		t.Fatalf("expected output format %q, got %q", values.OutputFormat, got)
+	}
+	if got := ConfigPathFromContext(ctx); got != values.ConfigPath {
+		t.Fatalf("expected config path %q, got %q", values.ConfigPath, got)
+	}
+	if got, ok := BuildInfoFromContext(ctx); !ok || got != values.BuildInfo {
+		t.Fatalf("expected seeded build info, got %v", got)
+	}
+	if got := CorrelationIDFromContext(ctx); got != values.CorrelationID {
+		t.Fatalf("expected correlation ID %q, got %q", values.CorrelationID, got)
+	}
+	if CobraCmdFromContext(ctx) != cmd {
+		t.Fatal("expected context to contain the cobra command")
+	}
+}
+
+func TestSeedContext_Defaults(t *testing.T) {
+	ctx := SeedContext(context.Background(), ContextValues{})
+
+	if got := LoggerFromContext(ctx); got != slog.Default() {
+		t.Fatalf("expected default logger, got %v", got)
+	}
+	if got := OutputFormatFromContext(ctx); got != OutputFormatText {
+		t.Fatalf("expected output format %q, got %q", OutputFormatText, got)
+	}
+	if got := ConfigPathFromContext(ctx); got != "" {
+		t.Fatalf("expected no config path, got %q", got)
+	}
+}
+
+type testCmdWithContextConfig struct {
+	testCmdWithConfig
+	ctx context.Context
+}
+
+func (c *testCmdWithContextConfig) Execute(ctx context.Context) error {
+	c.ctx = ctx
+	return nil
+}
+
+func TestContextValues_Execute(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "name: custom\n")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// without seeding, the decorators provide the values
+	c := &testCmdWithContextConfig{testCmdWithConfig: testCmdWithConfig{path: path}}
+	cmd := New(WithDecorators(CommandWithLoggerDecorator{Logger: logger})).MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := LoggerFromContext(c.ctx); got != logger {
+		t.Fatalf("expected logger of the decorator, got %v", got)
+	}
+	if got := ConfigPathFromContext(c.ctx); got != path {
+		t.Fatalf("expected config path %q, got %q", path, got)
+	}
+}
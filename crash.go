@@ -0,0 +1,194 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// redactedArg replaces argument values in crash reports.
+const redactedArg = "REDACTED"
+
+// CrashReport contains information about a panic that occurred while running a
+// command.
+type CrashReport struct {
+	// Command is the path of the executed command (e.g. "conduit pipelines").
+	// It is empty if the panic occurred before the command was built.
+	Command string
+	// Args are the arguments passed to the command. Flag values and positional
+	// arguments are replaced with "REDACTED", only flag and subcommand names
+	// are retained.
+	Args []string
+	// Panic is the value passed to panic, formatted as a string.
+	Panic string
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack string
+	// Version is the version of the root command or, if not set, the version of
+	// the main module.
+	Version string
+}
+
+// RunWithCrashReport works like Run, but additionally recovers panics that
+// occur while building or executing the command. On panic it builds a
+// CrashReport, passes it to the reporter and returns ExitCodeSoftware.
+//
+//	os.Exit(ecdysis.New().RunWithCrashReport(&RootCommand{}, sentry.Report))
+func (e *Ecdysis) RunWithCrashReport(c Command, reporter func(CrashReport)) int {
+	return e.runWithCrashReport(context.Background(), c, nil, reporter)
+}
+
+// runWithCrashReport executes the command with the provided args and recovers
+// panics, if args is nil os.Args is used.
+func (e *Ecdysis) runWithCrashReport(ctx context.Context, c Command, args []string, reporter func(CrashReport)) (code int) {
+	if args == nil {
+		args = os.Args[1:]
+	}
+
+	var cmd *cobra.Command
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		fmt.Fprintln(os.Stderr, "Error: unexpected panic:", r)
+		if reporter != nil {
+			reporter(newCrashReport(cmd, args, r, debug.Stack()))
+		}
+		code = ExitCodeSoftware
+	}()
+
+	cmd, err := e.BuildCobraCommand(c)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return ExitCodeError
+	}
+
+	return e.execute(ctx, cmd, args)
+}
+
+func newCrashReport(root *cobra.Command, args []string, r any, stack []byte) CrashReport {
+	report := CrashReport{
+		Panic: fmt.Sprint(r),
+		Stack: string(stack),
+		Args:  redactArgs(nil, args),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		report.Version = info.Main.Version
+	}
+	if root == nil {
+		return report
+	}
+
+	if root.Version != "" {
+		report.Version = root.Version
+	}
+
+	cmd, _, err := root.Find(args)
+	if err != nil || cmd == nil {
+		cmd = root
+	}
+	report.Command = cmd.CommandPath()
+	report.Args = redactArgs(cmd, args)
+	return report
+}
+
+// redactArgs replaces the values of flags and positional arguments with
+// redactedArg. Names of flags and of commands in the path of cmd are retained.
+// If cmd is nil, no flag set is available to tell values apart from names, so
+// all arguments except flag names are redacted, including values attached to
+// a shorthand (e.g. -psecret).
+func redactArgs(cmd *cobra.Command, args []string) []string {
+	names := make(map[string]bool)
+	lookup := func(string, bool) *pflag.Flag { return nil }
+	if cmd != nil {
+		for c := cmd; c != nil; c = c.Parent() {
+			names[c.Name()] = true
+			for _, alias := range c.Aliases {
+				names[alias] = true
+			}
+		}
+		lookup = func(name string, short bool) *pflag.Flag {
+			for _, fs := range []*pflag.FlagSet{cmd.Flags(), cmd.InheritedFlags()} {
+				if short {
+					if f := fs.ShorthandLookup(name); f != nil {
+						return f
+					}
+				} else if f := fs.Lookup(name); f != nil {
+					return f
+				}
+			}
+			return nil
+		}
+	}
+
+	redacted := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			// everything after "--" is a positional argument
+			redacted = append(redacted, arg)
+			for range args[i+1:] {
+				redacted = append(redacted, redactedArg)
+			}
+			return redacted
+		case strings.HasPrefix(arg, "--"):
+			name, _, hasValue := strings.Cut(arg, "=")
+			if hasValue {
+				redacted = append(redacted, name+"="+redactedArg)
+				continue
+			}
+			redacted = append(redacted, arg)
+			if f := lookup(arg[2:], false); f != nil && f.NoOptDefVal == "" && i+1 < len(args) {
+				redacted = append(redacted, redactedArg)
+				i++
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			f := lookup(arg[1:2], true)
+			if cmd == nil && len(arg) > 2 {
+				// without flags any attached characters may be a value
+				redacted = append(redacted, arg[:2]+redactedArg)
+				continue
+			}
+			if f == nil || f.NoOptDefVal != "" {
+				redacted = append(redacted, arg)
+				continue
+			}
+			if len(arg) > 2 {
+				// value is attached to the shorthand (e.g. -ofoo or -o=foo)
+				redacted = append(redacted, arg[:2]+redactedArg)
+				continue
+			}
+			redacted = append(redacted, arg)
+			if i+1 < len(args) {
+				redacted = append(redacted, redactedArg)
+				i++
+			}
+		case names[arg]:
+			redacted = append(redacted, arg)
+		default:
+			redacted = append(redacted, redactedArg)
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,91 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testCmdWithPanic struct {
+	flags struct {
+		Token   string `long:"token" short:"t"`
+		Verbose bool   `long:"verbose" short:"v"`
+	}
+}
+
+var (
+	_ CommandWithExecute = (*testCmdWithPanic)(nil)
+	_ CommandWithFlags   = (*testCmdWithPanic)(nil)
+)
+
+func (c *testCmdWithPanic) Usage() string                 { return "crash" }
+func (c *testCmdWithPanic) Flags() []Flag                 { return BuildFlags(&c.flags) }
+func (c *testCmdWithPanic) Execute(context.Context) error { panic("boom") }
+
+func TestEcdysis_RunWithCrashReport(t *testing.T) {
+	var reports []CrashReport
+	reporter := func(r CrashReport) { reports = append(reports, r) }
+
+	root := &testParentCmd{subCommands: []Command{&testCmdWithPanic{}}}
+	args := []string{"crash", "--token", "secret", "-v", "--token=secret", "-tsecret", "file.txt", "--", "-x"}
+
+	got := New().runWithCrashReport(context.Background(), root, args, reporter)
+	if got != ExitCodeSoftware {
+		t.Fatalf("expected exit code %d, got %d", ExitCodeSoftware, got)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+
+	report := reports[0]
+	if report.Command != "root crash" {
+		t.Fatalf("expected command %q, got %q", "root crash", report.Command)
+	}
+	if report.Panic != "boom" {
+		t.Fatalf("expected panic %q, got %q", "boom", report.Panic)
+	}
+	if !strings.Contains(report.Stack, "testCmdWithPanic") {
+		t.Fatalf("expected stack to contain the panicking command, got:\n%s", report.Stack)
+	}
+
+	wantArgs := []string{"crash", "--token", "REDACTED", "-v", "--token=REDACTED", "-tREDACTED", "REDACTED", "--", "REDACTED"}
+	if diff := cmp.Diff(wantArgs, report.Args); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestEcdysis_RunWithCrashReport_NoPanic(t *testing.T) {
+	reporter := func(CrashReport) { t.Fatal("unexpected crash report") }
+
+	got := New().runWithCrashReport(context.Background(), &testCmdWithError{}, []string{}, reporter)
+	if got != ExitCodeOK {
+		t.Fatalf("expected exit code %d, got %d", ExitCodeOK, got)
+	}
+}
+
+func TestNewCrashReport_NoCommand(t *testing.T) {
+	args := []string{"crash", "--token", "secret", "-psecret", "-p=secret", "-v", "--token=secret", "--", "-x"}
+
+	report := newCrashReport(nil, args, "boom", nil)
+
+	wantArgs := []string{"REDACTED", "--token", "REDACTED", "-pREDACTED", "-pREDACTED", "-v", "--token=REDACTED", "--", "REDACTED"}
+	if diff := cmp.Diff(wantArgs, report.Args); diff != "" {
+		t.Fatal(diff)
+	}
+}
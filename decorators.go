@@ -17,12 +17,19 @@ package ecdysis
 import (
 	"bufio"
 	"context"
+	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -32,8 +39,15 @@ import (
 
 var DefaultDecorators = []Decorator{
 	CommandWithLoggerDecorator{},
+	CommandWithOutputDecorator{},
 	CommandWithAliasesDecorator{},
 	CommandWithFlagsDecorator{},
+	FlagSuggestionsDecorator{},
+
+	// Entitlements and pre-run validations are checked before the
+	// configuration is parsed.
+	CommandWithEntitlementDecorator{},
+	CommandWithPreRunValidationDecorator{},
 
 	// CommandWithConfigDecorator needs to be after CommandWithFlagsDecorator to make sure the flags are parsed.
 	CommandWithConfigDecorator{},
@@ -41,15 +55,29 @@ var DefaultDecorators = []Decorator{
 	CommandWithDocsDecorator{},
 	CommandWithHiddenDecorator{},
 	CommandWithSubCommandsDecorator{},
+
+	// CommandWithVisibilityDecorator needs to be after CommandWithSubCommandsDecorator to see the subcommands.
+	CommandWithVisibilityDecorator{},
+
 	CommandWithDeprecatedDecorator{},
+	CommandWithArgCountDecorator{},
 	CommandWithArgsDecorator{},
+	CommandWithArgFlagDependenciesDecorator{},
 
 	// Confirm and Prompt need to go before Execute to make sure there's a
 	// confirmation prompt prior to execution.
 	CommandWithConfirmDecorator{},
 	CommandWithPromptDecorator{},
+	CommandWithAffectedCountDecorator{},
 
 	CommandWithExecuteDecorator{},
+	CommandWithBatchDecorator{},
+	CommandWithTableDecorator{},
+	CommandWithTemplateDecorator{},
+	CommandWithNextStepsDecorator{},
+
+	// Watch needs to go after Execute to re-run the whole execution.
+	CommandWithWatchDecorator{},
 }
 
 // -- LOGGER -------------------------------------------------------------------
@@ -61,28 +89,239 @@ type CommandWithLogger interface {
 	Logger(*slog.Logger)
 }
 
+// CommandWithLogFile can be implemented by a command to write its logs to a
+// separate file.
+type CommandWithLogFile interface {
+	CommandWithLogger
+	// LogFile returns the path of the file the logs are appended to. If tee
+	// is true, the logs are additionally passed to the default logger. It is
+	// called when the command is built.
+	LogFile() (path string, tee bool)
+}
+
 // CommandWithLoggerDecorator is a decorator that provides a logger to the command.
 // If the Logger field is not set, the default slog logger will be provided.
+// Commands implementing CommandWithLogFile get a logger writing to their log
+// file in the text format, using the level of the base logger. The file is
+// opened when the command starts executing and closed when it is done.
 type CommandWithLoggerDecorator struct {
 	Logger *slog.Logger
 }
 
-// Decorate provides the logger to the command.
-func (d CommandWithLoggerDecorator) Decorate(_ *Ecdysis, _ *cobra.Command, c Command) error {
-	v, ok := c.(CommandWithLogger)
+// Decorate provides the logger to the command and stores it in the context
+// (see LoggerFromContext).
+func (d CommandWithLoggerDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
+	logger := d.Logger
+	var file *logFile
+	if v, ok := c.(CommandWithLogger); ok {
+		logger, file = d.loggerFor(v)
+		v.Logger(logger)
+	}
+	e.logger = logger
+	if file != nil {
+		decorateLogFile(e, cmd, file)
+	}
+	if logger == nil {
+		// LoggerFromContext falls back to the default logger
+		return nil
+	}
+
+	old := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		cmd.SetContext(contextWithLogger(cmd.Context(), logger))
+		if old != nil {
+			return old(cmd, args)
+		}
+		return nil
+	}
+	return nil
+}
+
+// loggerFor returns the logger provided to the command and the log file it
+// writes to, if the command implements CommandWithLogFile.
+func (d CommandWithLoggerDecorator) loggerFor(c CommandWithLogger) (*slog.Logger, *logFile) {
+	logger := d.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	lf, ok := c.(CommandWithLogFile)
+	if !ok {
+		return logger, nil
+	}
+
+	path, tee := lf.LogFile()
+	file := &logFile{path: path}
+	var handler slog.Handler = slog.NewTextHandler(file, &slog.HandlerOptions{
+		Level: handlerLeveler{handler: logger.Handler()},
+	})
+	if tee {
+		handler = teeHandler{handler, logger.Handler()}
+	}
+	return slog.New(handler), file
+}
+
+// decorateLogFile opens the log file before the command is executed and
+// closes it after the command is done or failed. The file is closed by hooks
+// installed after all decorators, so failures in hooks of later decorators
+// close it as well.
+func decorateLogFile(e *Ecdysis, cmd *cobra.Command, file *logFile) {
+	oldPreRunE := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := file.open(); err != nil {
+			return err
+		}
+		if oldPreRunE != nil {
+			return oldPreRunE(cmd, args)
+		}
+		return nil
+	}
+
+	e.finishers = append(e.finishers, func(cmd *cobra.Command) {
+		// PostRunE is not executed if PreRunE or RunE fail
+		closeOnError := func(run func(*cobra.Command, []string) error) func(*cobra.Command, []string) error {
+			return func(cmd *cobra.Command, args []string) error {
+				if err := run(cmd, args); err != nil {
+					return errors.Join(err, file.close())
+				}
+				return nil
+			}
+		}
+		cmd.PreRunE = closeOnError(cmd.PreRunE)
+		if cmd.RunE != nil {
+			cmd.RunE = closeOnError(cmd.RunE)
+		}
+
+		oldPostRunE := cmd.PostRunE
+		cmd.PostRunE = func(cmd *cobra.Command, args []string) error {
+			var err error
+			if oldPostRunE != nil {
+				err = oldPostRunE(cmd, args)
+			}
+			return errors.Join(err, file.close())
+		}
+	})
+}
+
+// -- OUTPUT -------------------------------------------------------------------
+
+// CommandWithOutput can be implemented by a command to get an Output.
+type CommandWithOutput interface {
+	Command
+	// Output provides the output to the command.
+	Output(Output)
+}
+
+// CommandWithOutputDecorator is a decorator that provides an Output to the
+// command. By default, the output writes to the output and error output of the
+// cobra command (see WithOutput). It is provided right before the command is
+// executed.
+type CommandWithOutputDecorator struct{}
+
+// Decorate provides the output to the command.
+func (CommandWithOutputDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithOutput)
 	if !ok {
 		return nil
 	}
 
-	if d.Logger == nil {
-		v.Logger(slog.Default())
-	} else {
-		v.Logger(d.Logger)
+	old := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		// the writers are resolved at execution, they could be changed after
+		// the command was built (e.g. using cmd.SetOut)
+		v.Output(e.output(cmd))
+		if old != nil {
+			return old(cmd, args)
+		}
+		return nil
 	}
+	return nil
+}
+
+// -- CORRELATION ID -----------------------------------------------------------
+
+// correlationIDLogKey is the key of the logger attribute containing the
+// correlation ID.
+const correlationIDLogKey = "correlation_id"
+
+// CorrelationIDDecorator is a decorator that assigns a correlation ID to each
+// invocation of a command. The ID is stored in the context (see
+// CorrelationIDFromContext) and added as an attribute to the logger in the
+// context (see LoggerFromContext) and the logger provided to commands
+// implementing CommandWithLogger. If the context already contains a correlation
+// ID (e.g. seeded using SeedContext), it is kept. It is not part of the default
+// decorators, add it using WithDecorators.
+type CorrelationIDDecorator struct {
+	// EnvVar is the name of an environment variable that can contain the
+	// correlation ID (e.g. to continue a distributed trace). If the variable
+	// is empty or not set, a random UUID is generated.
+	EnvVar string
+}
+
+// Decorate injects the correlation ID into the context and logger of the
+// command.
+func (d CorrelationIDDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
+	old := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		id, err := d.correlationID(cmd.Context())
+		if err != nil {
+			return err
+		}
+		ctx := ContextWithCorrelationID(cmd.Context(), id)
+
+		// the logger is resolved at execution, it is provided by
+		// CommandWithLoggerDecorator regardless of the decorator order
+		logger, ok := ctx.Value(loggerCtxKey{}).(*slog.Logger)
+		if !ok {
+			logger = e.logger
+		}
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger = logger.With(correlationIDLogKey, id)
+		cmd.SetContext(context.WithValue(ctx, loggerCtxKey{}, logger))
+		if v, ok := c.(CommandWithLogger); ok {
+			v.Logger(logger)
+		}
 
+		if old != nil {
+			return old(cmd, args)
+		}
+		return nil
+	}
 	return nil
 }
 
+// correlationID returns the correlation ID of the invocation. It is taken
+// from the context, the environment variable EnvVar or generated, in that
+// order.
+func (d CorrelationIDDecorator) correlationID(ctx context.Context) (string, error) {
+	if id := CorrelationIDFromContext(ctx); id != "" {
+		return id, nil
+	}
+	if d.EnvVar != "" {
+		if id := os.Getenv(d.EnvVar); id != "" {
+			return id, nil
+		}
+	}
+	id, err := newUUID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate correlation ID: %w", err)
+	}
+	return id, nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err //nolint:wrapcheck // wrapped by the caller
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
 // -- ALIASES ------------------------------------------------------------------
 
 // CommandWithAliases can be implemented by a command to provide aliases.
@@ -120,14 +359,19 @@ type CommandWithFlags interface {
 type CommandWithFlagsDecorator struct{}
 
 // Decorate sets the command flags.
-//
-//nolint:funlen,gocyclo,gocognit,forcetypeassert // this function has a big switch statement, can't get around that
 func (CommandWithFlagsDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
 	v, ok := c.(CommandWithFlags)
 	if !ok {
 		return nil
 	}
 
+	var (
+		// checks are validations executed after the flags are parsed.
+		checks []func() error
+		// defaultFuncs are flags with defaults computed after the flags are
+		// parsed.
+		defaultFuncs []Flag
+	)
 	for _, f := range v.Flags() {
 		var flags *pflag.FlagSet
 		if f.Persistent {
@@ -136,134 +380,17 @@ func (CommandWithFlagsDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Comm
 			flags = cmd.Flags()
 		}
 
-		if f.Required {
-			f.Usage += " (required)"
-		}
-
-		switch val := f.Ptr.(type) {
-		case *string:
-			if f.Default == nil {
-				f.Default = ""
-			}
-			flags.StringVarP(val, f.Long, f.Short, f.Default.(string), f.Usage)
-		case *int:
-			if f.Default == nil {
-				f.Default = 0
-			}
-			flags.IntVarP(val, f.Long, f.Short, f.Default.(int), f.Usage)
-		case *int8:
-			if f.Default == nil {
-				f.Default = int8(0)
-			}
-			flags.Int8VarP(val, f.Long, f.Short, f.Default.(int8), f.Usage)
-		case *int16:
-			if f.Default == nil {
-				f.Default = int16(0)
-			}
-			flags.Int16VarP(val, f.Long, f.Short, f.Default.(int16), f.Usage)
-		case *int32:
-			if f.Default == nil {
-				f.Default = int32(0)
-			}
-			flags.Int32VarP(val, f.Long, f.Short, f.Default.(int32), f.Usage)
-		case *int64:
-			if f.Default == nil {
-				f.Default = int64(0)
-			}
-			flags.Int64VarP(val, f.Long, f.Short, f.Default.(int64), f.Usage)
-		case *float32:
-			if f.Default == nil {
-				f.Default = float32(0)
-			}
-			flags.Float32VarP(val, f.Long, f.Short, f.Default.(float32), f.Usage)
-		case *float64:
-			if f.Default == nil {
-				f.Default = float64(0)
-			}
-			flags.Float64VarP(val, f.Long, f.Short, f.Default.(float64), f.Usage)
-		case *bool:
-			if f.Default == nil {
-				f.Default = false
-			}
-			flags.BoolVarP(val, f.Long, f.Short, f.Default.(bool), f.Usage)
-		case *time.Duration:
-			if f.Default == nil {
-				f.Default = time.Duration(0)
-			}
-			flags.DurationVarP(val, f.Long, f.Short, f.Default.(time.Duration), f.Usage)
-		case *[]bool:
-			if f.Default == nil {
-				f.Default = []bool(nil)
-			}
-			flags.BoolSliceVarP(val, f.Long, f.Short, f.Default.([]bool), f.Usage)
-		case *[]float32:
-			if f.Default == nil {
-				f.Default = []float32(nil)
-			}
-			flags.Float32SliceVarP(val, f.Long, f.Short, f.Default.([]float32), f.Usage)
-		case *[]float64:
-			if f.Default == nil {
-				f.Default = []float64(nil)
-			}
-			flags.Float64SliceVarP(val, f.Long, f.Short, f.Default.([]float64), f.Usage)
-		case *[]int32:
-			if f.Default == nil {
-				f.Default = []int32(nil)
-			}
-			flags.Int32SliceVarP(val, f.Long, f.Short, f.Default.([]int32), f.Usage)
-		case *[]int64:
-			if f.Default == nil {
-				f.Default = []int64(nil)
-			}
-			flags.Int64SliceVarP(val, f.Long, f.Short, f.Default.([]int64), f.Usage)
-		case *[]int:
-			if f.Default == nil {
-				f.Default = []int(nil)
-			}
-			flags.IntSliceVarP(val, f.Long, f.Short, f.Default.([]int), f.Usage)
-		case *[]string:
-			if f.Default == nil {
-				f.Default = []string(nil)
-			}
-			flags.StringSliceVarP(val, f.Long, f.Short, f.Default.([]string), f.Usage)
-		default:
-			return fmt.Errorf("unexpected flag value type: %T", val)
-		}
-
-		if f.Required {
-			err := cobra.MarkFlagRequired(flags, f.Long)
-			if err != nil {
-				return fmt.Errorf("could not mark flag required: %w", err)
-			}
+		fchecks, err := applyFlag(flags, f)
+		if err != nil {
+			return err
 		}
-
-		if f.Hidden {
-			err := flags.MarkHidden(f.Long)
-			if err != nil {
-				return fmt.Errorf("could not mark flag hidden: %w", err)
-			}
+		checks = append(checks, fchecks...)
+		if f.DefaultFunc != nil {
+			defaultFuncs = append(defaultFuncs, f)
 		}
 	}
 
-	return nil
-}
-
-// -- PARSING CONFIGURATION --------------------------------------------------------------------
-
-// CommandWithConfig can be implemented by a command to parsing configuration.
-type CommandWithConfig interface {
-	Command
-
-	Config() Config
-}
-
-// CommandWithConfigDecorator is a decorator that sets the command flags.
-type CommandWithConfigDecorator struct{}
-
-// Decorate parses the configuration based on flags.
-func (CommandWithConfigDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
-	v, ok := c.(CommandWithConfig)
-	if !ok {
+	if len(checks) == 0 && len(defaultFuncs) == 0 {
 		return nil
 	}
 
@@ -276,146 +403,99 @@ func (CommandWithConfigDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Com
 			}
 		}
 
-		cfg := v.Config()
-
-		parsedType := reflect.TypeOf(cfg.Parsed)
-
-		// Ensure Parsed is a pointer
-		if parsedType.Kind() != reflect.Ptr {
-			return fmt.Errorf("parsed must be a pointer")
-		}
-
-		if parsedType.Elem() != reflect.TypeOf(cfg.DefaultValues) {
-			return fmt.Errorf("parsed and defaultValues must be the same type")
-		}
-
-		viper := viper.New()
-
-		setDefaults(viper, cfg.DefaultValues)
-
-		if err := parseConfig(viper, cfg, cmd); err != nil {
-			return fmt.Errorf("error parsing config: %w", err)
+		// defaults are computed before the checks, so they are validated
+		for _, f := range defaultFuncs {
+			if err := f.applyDefaultFunc(cmd.Flags()); err != nil {
+				return err
+			}
 		}
-
-		if err := viper.Unmarshal(cfg.Parsed); err != nil {
-			return fmt.Errorf("error unmarshalling config: %w", err)
+		for _, check := range checks {
+			if err := check(); err != nil {
+				return err
+			}
 		}
 		return nil
 	}
-	return nil
-}
-
-// -- DOCS ---------------------------------------------------------------------
-
-// CommandWithDocs can be implemented by a command to provide documentation.
-type CommandWithDocs interface {
-	Command
-	// Docs returns the documentation for the command.
-	Docs() Docs
-}
 
-// Docs will be shown to the user when typing 'help' as well as in generated docs.
-type Docs struct {
-	// Short is the short description shown in the 'help' output.
-	Short string
-	// Long is the long message shown in the 'help <this-command>' output.
-	Long string
-	// Example is examples of how to use the command.
-	Example string
+	return nil
 }
 
-// CommandWithDocsDecorator is a decorator that sets the command documentation.
-type CommandWithDocsDecorator struct{}
-
-// Decorate sets the command documentation.
-func (CommandWithDocsDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
-	v, ok := c.(CommandWithDocs)
-	if !ok {
-		return nil
-	}
+// -- FLAG SUGGESTIONS ---------------------------------------------------------
 
-	docs := v.Docs()
-	cmd.Long = docs.Long
-	cmd.Short = docs.Short
-	cmd.Example = docs.Example
+// FlagSuggestionsDecorator is a decorator that improves the error returned when
+// an unknown flag is supplied by suggesting the closest known flag.
+type FlagSuggestionsDecorator struct{}
 
+// Decorate sets the flag error function of the command.
+func (FlagSuggestionsDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, _ Command) error {
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		typed, ok := strings.CutPrefix(err.Error(), "unknown flag: --")
+		if !ok {
+			return err
+		}
+		if suggestion, ok := suggestFlag(cmd.Flags(), typed); ok {
+			return fmt.Errorf("%w\n\nDid you mean this?\n\t--%s", err, suggestion)
+		}
+		return err
+	})
 	return nil
 }
 
-// -- HIDDEN -------------------------------------------------------------------
+// -- ENTITLEMENT --------------------------------------------------------------
 
-// CommandWithHidden can be implemented by a command to hide it from the help.
-type CommandWithHidden interface {
+// CommandWithEntitlement can be implemented by a command that requires an
+// entitlement (e.g. a feature included in a license) to be executed.
+type CommandWithEntitlement interface {
 	Command
-	// Hidden returns the desired hidden value for the command.
-	Hidden() bool
+	// Entitlement returns the name of the entitlement required to execute the
+	// command.
+	Entitlement() string
 }
 
-// CommandWithHiddenDecorator is a decorator that sets the command hidden value.
-type CommandWithHiddenDecorator struct{}
-
-// Decorate sets the command hidden value.
-func (CommandWithHiddenDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
-	v, ok := c.(CommandWithHidden)
-	if !ok {
-		return nil
-	}
-
-	cmd.Hidden = v.Hidden()
-	return nil
+// EntitlementChecker checks if the user of the CLI has an entitlement.
+type EntitlementChecker interface {
+	// HasEntitlement returns true if the user has the entitlement.
+	HasEntitlement(ctx context.Context, entitlement string) (bool, error)
 }
 
-// -- SUB COMMANDS -------------------------------------------------------------
-
-// CommandWithSubCommands can be implemented by a command to provide subcommands.
-type CommandWithSubCommands interface {
-	Command
-	// SubCommands defines subcommands of a command.
-	SubCommands() []Command
+// CommandWithEntitlementDecorator is a decorator that prevents the execution of
+// a command if the user doesn't have the required entitlement.
+type CommandWithEntitlementDecorator struct {
+	// Checker is used to check entitlements. If nil, entitlements are not
+	// checked.
+	Checker EntitlementChecker
+	// UpgradeMessage is included in the error returned when the entitlement is
+	// missing. If empty, a generic message is used.
+	UpgradeMessage string
+	// HideUnentitled hides commands from the help output if the user doesn't
+	// have the required entitlement. Note that in this case the entitlement is
+	// checked while building the command.
+	HideUnentitled bool
 }
 
-// CommandWithSubCommandsDecorator is a decorator that sets the command subcommands.
-type CommandWithSubCommandsDecorator struct{}
-
-// Decorate sets the command subcommands.
-func (CommandWithSubCommandsDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
-	v, ok := c.(CommandWithSubCommands)
-	if !ok {
+// Decorate sets up the entitlement check before executing the command.
+func (d CommandWithEntitlementDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithEntitlement)
+	if !ok || d.Checker == nil {
 		return nil
 	}
 
-	for _, sub := range v.SubCommands() {
-		subCmd, err := e.BuildCobraCommand(sub)
+	entitlement := v.Entitlement()
+	if d.HideUnentitled {
+		ok, err := d.Checker.HasEntitlement(context.Background(), entitlement)
 		if err != nil {
-			return fmt.Errorf("failed to build subcommand %q: %w", sub.Usage(), err)
+			return fmt.Errorf("failed to check entitlement %q: %w", entitlement, err)
+		}
+		if !ok {
+			cmd.Hidden = true
 		}
-		cmd.AddCommand(subCmd)
 	}
-	return nil
-}
-
-// -- DEPRECATED ---------------------------------------------------------------
-
-// CommandWithDeprecated can be implemented by a command to mark it as deprecated
-// and print a message when it is used.
-type CommandWithDeprecated interface {
-	Command
-	// Deprecated returns a message that will be printed when the command is used.
-	Deprecated() string
-}
 
-// CommandWithDeprecatedDecorator is a decorator that deprecates the command.
-type CommandWithDeprecatedDecorator struct{}
-
-// Decorate deprecates the command.
-func (CommandWithDeprecatedDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
-	v, ok := c.(CommandWithDeprecated)
-	if !ok {
-		return nil
+	upgradeMessage := d.UpgradeMessage
+	if upgradeMessage == "" {
+		upgradeMessage = "upgrade your license to use this command"
 	}
 
-	cmd.Hidden = true
-
 	old := cmd.PreRunE
 	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
 		if old != nil {
@@ -423,39 +503,43 @@ func (CommandWithDeprecatedDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c
 			if err != nil {
 				return err
 			}
-
-			if cmd.Flags().Changed("json") {
-				return nil
-			}
-
-			c := cmd.Name()
-			if cmd.HasParent() {
-				c = fmt.Sprintf("%s %s", cmd.Parent().Name(), c)
-			}
-			fmt.Printf("Command %q is deprecated, %s\n", c, v.Deprecated())
 		}
 
+		ok, err := d.Checker.HasEntitlement(cmd.Context(), entitlement)
+		if err != nil {
+			return fmt.Errorf("failed to check entitlement %q: %w", entitlement, err)
+		}
+		if !ok {
+			return fmt.Errorf("%w: command %q requires entitlement %q, %s", ErrPermission, cmd.CommandPath(), entitlement, upgradeMessage)
+		}
 		return nil
 	}
 
 	return nil
 }
 
-// -- ARGS ---------------------------------------------------------------------
+// -- PRE-RUN VALIDATION -------------------------------------------------------
 
-// CommandWithArgs can be implemented by a command to parse arguments.
-type CommandWithArgs interface {
+// CommandWithPreRunValidation can be implemented by a command to run checks
+// that need to pass before the configuration is parsed (e.g. a required
+// environment variable exists).
+type CommandWithPreRunValidation interface {
 	Command
-	// Args is meant to parse arguments after the command name.
-	Args([]string) error
+	// PreRunValidate is called after flags are parsed and entitlements are
+	// checked, but before the configuration is parsed. The configuration
+	// struct is not populated yet when it is called.
+	PreRunValidate(context.Context) error
 }
 
-// CommandWithArgsDecorator is a decorator that provides the command arguments.
-type CommandWithArgsDecorator struct{}
+// CommandWithPreRunValidationDecorator is a decorator that runs the pre-run
+// validation of the command. In DefaultDecorators it is placed before
+// CommandWithConfigDecorator, so that the validation runs before the
+// configuration is read.
+type CommandWithPreRunValidationDecorator struct{}
 
-// Decorate provides the command arguments.
-func (CommandWithArgsDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
-	v, ok := c.(CommandWithArgs)
+// Decorate runs the pre-run validation before executing the command.
+func (CommandWithPreRunValidationDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithPreRunValidation)
 	if !ok {
 		return nil
 	}
@@ -468,47 +552,1325 @@ func (CommandWithArgsDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Comma
 				return err
 			}
 		}
-		return v.Args(args)
+		return v.PreRunValidate(cmd.Context())
 	}
 	return nil
 }
 
-// -- CONFIRM ------------------------------------------------------------------
+// -- PARSING CONFIGURATION --------------------------------------------------------------------
 
-// CommandWithConfirm can be implemented by a command to require confirmation
-// before execution. The user will be prompted to enter a specific value.
-// If the value matches, the command will be executed, otherwise it will be
-// aborted.
-type CommandWithConfirm interface {
+// CommandWithConfig can be implemented by a command to parsing configuration.
+// The parsed configuration is also stored in the context passed to Execute (see
+// ConfigFromContext).
+type CommandWithConfig interface {
+	Command
+
+	Config() Config
+}
+
+// CommandWithConfigDecorator is a decorator that sets the command flags.
+type CommandWithConfigDecorator struct {
+	// InlineFlag is the name of a flag that accepts the whole configuration
+	// as an inline JSON or YAML document (e.g. "config-inline"). Values
+	// supplied this way take precedence over all other configuration sources.
+	// If empty, the flag is not registered.
+	InlineFlag string
+	// SetFlag is the name of a repeatable flag that overrides single
+	// configuration values (e.g. "set", used as --set database.host=db1).
+	// Nested values are addressed using dotted paths, which need to resolve
+	// to a field in the configuration struct. Values supplied this way take
+	// precedence over all other configuration sources, including the inline
+	// configuration. If empty, the flag is not registered.
+	SetFlag string
+}
+
+// Decorate parses the configuration based on flags.
+func (d CommandWithConfigDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithConfig)
+	if !ok {
+		return nil
+	}
+
+	var inline string
+	if d.InlineFlag != "" {
+		cmd.Flags().StringVar(&inline, d.InlineFlag, "", "configuration as an inline JSON or YAML document, takes precedence over other configuration sources")
+	}
+	var overrides []string
+	if d.SetFlag != "" {
+		cmd.Flags().StringArrayVar(&overrides, d.SetFlag, nil, "override a configuration value (e.g. database.host=db1), can be repeated")
+	}
+
+	// parseInto parses the configuration into parsed, which needs to be of
+	// the same type as Config.Parsed
+	parseInto := func(cmd *cobra.Command, parsed any) error {
+		cfg := v.Config()
+		cfg.Parsed = parsed
+
+		parsedType := reflect.TypeOf(cfg.Parsed)
+
+		// Ensure Parsed is a pointer
+		if parsedType.Kind() != reflect.Ptr {
+			return fmt.Errorf("parsed must be a pointer")
+		}
+
+		if parsedType.Elem() != reflect.TypeOf(cfg.DefaultValues) {
+			return fmt.Errorf("parsed and defaultValues must be the same type")
+		}
+
+		viper := viper.New()
+
+		if err := parseConfig(viper, cfg, cmd); err != nil {
+			return fmt.Errorf("error parsing config: %w", err)
+		}
+
+		if inline != "" {
+			if err := mergeInlineConfig(viper, inline); err != nil {
+				return fmt.Errorf("error parsing inline config: %w", err)
+			}
+		}
+
+		if len(overrides) > 0 {
+			if err := applyConfigOverrides(viper, overrides, configKeys(parsedType.Elem(), "")); err != nil {
+				return fmt.Errorf("invalid flag --%s: %w", d.SetFlag, err)
+			}
+		}
+
+		// defaults are set after checking mutually exclusive keys, so that
+		// only explicitly set keys are taken into account
+		if err := checkMutuallyExclusive(viper, cfg.MutuallyExclusive); err != nil {
+			return err
+		}
+
+		setDefaults(viper, cfg.DefaultValues, cfg.MapstructureKeys)
+		if err := setEmbeddedDefaults(viper, cfg.EmbeddedDefault); err != nil {
+			return fmt.Errorf("error parsing embedded default config: %w", err)
+		}
+		if e.profileDefaults != nil {
+			for key, val := range e.profileDefaults() {
+				viper.SetDefault(key, val)
+			}
+		}
+
+		if err := viper.Unmarshal(cfg.Parsed); err != nil {
+			return fmt.Errorf("error unmarshalling config: %w", err)
+		}
+		return nil
+	}
+	parse := func(cmd *cobra.Command) error {
+		return parseInto(cmd, v.Config().Parsed)
+	}
+	e.helpConfigParsers = append(e.helpConfigParsers, func() error {
+		// the standard input can only be read once, it's left for the
+		// execution of the command
+		if v.Config().configPath() == stdinConfigPath {
+			return nil
+		}
+		return parse(cmd)
+	})
+	e.configReloader = parseInto
+
+	old := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if old != nil {
+			err := old(cmd, args)
+			if err != nil {
+				return err
+			}
+		}
+		// the context of the command is kept between executions, the config
+		// in the standard input is read again in every execution
+		cmd.SetContext(contextWithStdinConfig(cmd.Context()))
+		if err := parse(cmd); err != nil {
+			return err
+		}
+		// the parsed configuration is available in the context of Execute
+		ctx := ContextWithConfig(cmd.Context(), v.Config().Parsed)
+		if path := v.Config().configPath(); path != "" {
+			ctx = contextWithConfigPath(ctx, path)
+		}
+		cmd.SetContext(ctx)
+		return nil
+	}
+	return nil
+}
+
+// -- CONFIG RELOAD ------------------------------------------------------------
+
+// CommandWithConfigReload can be implemented by a command reloading its
+// configuration (see WithConfigReload) to be notified about the reload.
+type CommandWithConfigReload interface {
+	CommandWithConfig
+	// OnConfigReload is called after the configuration was reloaded into
+	// Config.Parsed.
+	OnConfigReload(ctx context.Context)
+}
+
+// ConfigReloadDecorator is a decorator that reloads the configuration of
+// commands implementing CommandWithConfig and CommandWithExecute when the
+// process receives SIGHUP while the command is executed. The configuration is
+// parsed the same way as before the execution (i.e. the configuration file
+// and environment variables are read again, flags keep their values) and
+// replaces the value in Config.Parsed while holding the lock returned by
+// RLockConfig. ConfigFromContext returns a copy of the latest configuration,
+// which is safe to read without the lock. If the configuration can't be
+// parsed, the error is written to the error output and the previous
+// configuration is kept. Configuration read from the standard input is not
+// reloaded.
+//
+// The decorator needs to run after CommandWithConfigDecorator and is not part
+// of DefaultDecorators, enable it using WithConfigReload.
+type ConfigReloadDecorator struct {
+	// Notify relays the signals triggering a reload to the channel and
+	// returns a function that stops relaying them. If nil, SIGHUP is relayed
+	// using signal.Notify.
+	Notify func(c chan<- os.Signal) (stop func())
+}
+
+// WithConfigReload enables the ConfigReloadDecorator, reloading the
+// configuration on SIGHUP.
+func WithConfigReload() Option {
+	return WithDecorators(ConfigReloadDecorator{})
+}
+
+// Decorate reloads the configuration on SIGHUP while the command is executed.
+func (d ConfigReloadDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithConfig)
+	if !ok {
+		return nil
+	}
+	if _, ok := c.(CommandWithExecute); !ok {
+		return nil
+	}
+	parseInto := e.configReloader
+	if parseInto == nil {
+		return nil
+	}
+
+	notify := d.Notify
+	if notify == nil {
+		notify = func(c chan<- os.Signal) func() {
+			signal.Notify(c, syscall.SIGHUP)
+			return func() { signal.Stop(c) }
+		}
+	}
+
+	old := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if v.Config().configPath() == stdinConfigPath {
+			// the standard input can't be read again
+			return old(cmd, args)
+		}
+
+		// values returned by ConfigFromContext are copies of the parsed
+		// configuration, which are never modified
+		parsed := v.Config().Parsed
+		cp := reflect.New(reflect.TypeOf(parsed).Elem())
+		cp.Elem().Set(reflect.ValueOf(parsed).Elem())
+		rc := &reloadableConfig{parsed: cp.Interface()}
+		reload := func(cmd *cobra.Command) error {
+			next := reflect.New(reflect.TypeOf(parsed).Elem())
+			if err := parseInto(cmd, next.Interface()); err != nil {
+				return err
+			}
+
+			rc.mu.Lock()
+			defer rc.mu.Unlock()
+			reflect.ValueOf(parsed).Elem().Set(next.Elem())
+			rc.parsed = next.Interface()
+			return nil
+		}
+
+		ctx := contextWithReloadableConfig(cmd.Context(), rc)
+		cmd.SetContext(ctx)
+
+		signals := make(chan os.Signal, 1)
+		stop := notify(signals)
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				case <-signals:
+				}
+				if err := reload(cmd); err != nil {
+					e.output(cmd).Stderr(fmt.Sprintf("failed to reload configuration: %v\n", err))
+					continue
+				}
+				if r, ok := c.(CommandWithConfigReload); ok {
+					r.OnConfigReload(ctx)
+				}
+			}
+		}()
+		defer func() {
+			stop()
+			close(done)
+			wg.Wait()
+		}()
+
+		return old(cmd, args)
+	}
+
+	return nil
+}
+
+// -- PROFILES -----------------------------------------------------------------
+
+// ProfileDecorator is a decorator that loads the defaults of flags from a
+// profile in the config file of commands implementing CommandWithConfig. It
+// registers the flag --profile, when a profile is selected its values are used
+// as the defaults of flags with a matching name (or config key). The defaults
+// are shown in the help and can be overridden on the command line. In the
+// parsed configuration, values of the profile replace the default values
+// (DefaultValues and EmbeddedDefault), all other sources take precedence. As
+// they are defaults, they don't count as flags supplied by the user (e.g. for
+// required flags). Profiles are stored in the config file under the key
+// "profiles":
+//
+//	profiles:
+//	  staging:
+//	    host: staging.example.com
+//
+// The decorator is not part of DefaultDecorators, enable it using
+// WithProfiles.
+type ProfileDecorator struct {
+	// Flag is the name of the flag selecting the profile. If empty, "profile"
+	// is used.
+	Flag string
+	// Key is the config key containing the profiles. If empty, "profiles" is
+	// used.
+	Key string
+}
+
+// WithProfiles enables the ProfileDecorator.
+func WithProfiles() Option {
+	return WithDecorators(ProfileDecorator{})
+}
+
+// Decorate registers the profile flag and applies the defaults of the selected
+// profile before the command is executed or its help is rendered.
+func (d ProfileDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithConfig)
+	if !ok {
+		return nil
+	}
+	if d.Flag == "" {
+		d.Flag = "profile"
+	}
+	if d.Key == "" {
+		d.Key = "profiles"
+	}
+
+	var (
+		profile string
+		values  *viper.Viper
+	)
+	cmd.Flags().StringVar(&profile, d.Flag, "", "profile in the config file providing the default values of flags")
+
+	// the values are used as defaults when CommandWithConfigDecorator parses
+	// the configuration
+	e.profileDefaults = func() map[string]any {
+		if values == nil {
+			return nil
+		}
+		defaults := make(map[string]any)
+		for _, key := range values.AllKeys() {
+			defaults[key] = values.Get(key)
+		}
+		return defaults
+	}
+
+	apply := func(cmd *cobra.Command) error {
+		values = nil
+		if profile == "" {
+			return nil
+		}
+		var err error
+		values, err = d.loadProfile(v.Config(), profile)
+		if err != nil {
+			return fmt.Errorf("invalid flag --%s: %w", d.Flag, err)
+		}
+		return applyProfileDefaults(cmd.Flags(), values)
+	}
+
+	old := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		// The defaults need to be applied before the other decorators
+		// (e.g. CommandWithConfigDecorator) read the flags.
+		if err := apply(cmd); err != nil {
+			return err
+		}
+		if old != nil {
+			return old(cmd, args)
+		}
+		return nil
+	}
+
+	oldHelp := cmd.HelpFunc()
+	cmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		// errors are reported once the command is executed
+		_ = apply(cmd)
+		oldHelp(cmd, args)
+	})
+	return nil
+}
+
+// loadProfile reads the values of the profile from the config file.
+func (d ProfileDecorator) loadProfile(cfg Config, profile string) (*viper.Viper, error) {
+	path := cfg.configPath()
+	if path == "" || path == stdinConfigPath {
+		return nil, fmt.Errorf("profiles need to be defined in a config file")
+	}
+
+	v := viper.New()
+	if cfg.Type != "" {
+		v.SetConfigType(cfg.Type)
+	}
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("could not read config file: %w", err)
+	}
+
+	values := v.Sub(d.Key + "." + profile)
+	if values == nil {
+		return nil, fmt.Errorf("unknown profile %q", profile)
+	}
+	return values, nil
+}
+
+// applyProfileDefaults sets the values of the profile as the values and
+// defaults of flags that were not supplied on the command line, without marking
+// them as changed.
+func applyProfileDefaults(flags *pflag.FlagSet, values *viper.Viper) error {
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+		key := f.Name
+		if keys := f.Annotations[configKeyAnnotation]; len(keys) > 0 {
+			key = keys[0]
+		}
+		if !values.IsSet(key) {
+			return
+		}
+
+		val := values.Get(key)
+		var setErr error
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			setErr = sv.Replace(values.GetStringSlice(key))
+		} else {
+			setErr = f.Value.Set(fmt.Sprint(val))
+		}
+		if setErr != nil {
+			err = fmt.Errorf("invalid value %v for flag --%s in profile: %w", val, f.Name, setErr)
+			return
+		}
+		// the flag is not marked as changed, the value is a default
+		f.DefValue = f.Value.String()
+	})
+	return err
+}
+
+// -- DOCS ---------------------------------------------------------------------
+
+// CommandWithDocs can be implemented by a command to provide documentation.
+type CommandWithDocs interface {
+	Command
+	// Docs returns the documentation for the command.
+	Docs() Docs
+}
+
+// Docs will be shown to the user when typing 'help' as well as in generated docs.
+type Docs struct {
+	// Short is the short description shown in the 'help' output.
+	Short string
+	// Long is the long message shown in the 'help <this-command>' output.
+	Long string
+	// Example is examples of how to use the command.
+	Example string
+	// RunnableExamples are examples that can be executed in tests using
+	// RunExamples, which keeps them from going stale. Each example is a single
+	// command line starting with the name of the root command (e.g.
+	// "mycli pipelines list --output json"). They are appended to Example in
+	// the help output.
+	RunnableExamples []string
+}
+
+// CommandWithDocsDecorator is a decorator that sets the command documentation.
+type CommandWithDocsDecorator struct{}
+
+// Decorate sets the command documentation.
+func (CommandWithDocsDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithDocs)
+	if !ok {
+		return nil
+	}
+
+	docs := v.Docs()
+	cmd.Long = docs.Long
+	cmd.Short = docs.Short
+	cmd.Example = docs.Example
+
+	if len(docs.RunnableExamples) > 0 {
+		examples := make([]string, 0, len(docs.RunnableExamples)+1)
+		if docs.Example != "" {
+			examples = append(examples, docs.Example)
+		}
+		for _, ex := range docs.RunnableExamples {
+			examples = append(examples, "  "+ex)
+		}
+		cmd.Example = strings.Join(examples, "\n")
+
+		if cmd.Annotations == nil {
+			cmd.Annotations = make(map[string]string)
+		}
+		cmd.Annotations[runnableExamplesAnnotation] = strings.Join(docs.RunnableExamples, "\n")
+	}
+
+	return nil
+}
+
+// -- HIDDEN -------------------------------------------------------------------
+
+// CommandWithHidden can be implemented by a command to hide it from the help.
+type CommandWithHidden interface {
+	Command
+	// Hidden returns the desired hidden value for the command.
+	Hidden() bool
+}
+
+// CommandWithHiddenDecorator is a decorator that sets the command hidden value.
+type CommandWithHiddenDecorator struct{}
+
+// Decorate sets the command hidden value.
+func (CommandWithHiddenDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithHidden)
+	if !ok {
+		return nil
+	}
+
+	cmd.Hidden = v.Hidden()
+	return nil
+}
+
+// -- SUB COMMANDS -------------------------------------------------------------
+
+// CommandWithSubCommands can be implemented by a command to provide subcommands.
+type CommandWithSubCommands interface {
+	Command
+	// SubCommands defines subcommands of a command.
+	SubCommands() []Command
+}
+
+// CommandWithSubCommandsDecorator is a decorator that sets the command subcommands.
+type CommandWithSubCommandsDecorator struct{}
+
+// Decorate sets the command subcommands.
+func (CommandWithSubCommandsDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithSubCommands)
+	if !ok {
+		return nil
+	}
+
+	for _, sub := range v.SubCommands() {
+		subCmd, err := e.BuildCobraCommand(sub)
+		if err != nil {
+			return fmt.Errorf("failed to build subcommand %q: %w", sub.Usage(), err)
+		}
+		cmd.AddCommand(subCmd)
+
+		if vis, ok := sub.(CommandWithVisibility); ok {
+			e.visibleSubCommands = append(e.visibleSubCommands, visibleSubCommand{
+				cmd:     subCmd,
+				visible: vis.Visible,
+			})
+		}
+	}
+	return nil
+}
+
+// -- VISIBILITY ---------------------------------------------------------------
+
+// CommandWithVisibility can be implemented by a command to show it in the help
+// of its parent only if a condition is met (e.g. a feature is enabled in the
+// configuration). The command can still be executed when it's not visible.
+type CommandWithVisibility interface {
+	Command
+	// Visible reports whether the command should be shown in the help. It is
+	// evaluated right before the help of the parent command is rendered, after
+	// the configuration of the parent command and its ancestors is parsed.
+	Visible() bool
+}
+
+// CommandWithVisibilityDecorator is a decorator that hides subcommands
+// implementing CommandWithVisibility from the help when they are not visible.
+// Subcommands hidden for other reasons (e.g. CommandWithHidden) stay hidden.
+// The configuration is not parsed before the help is rendered if it is read
+// from the standard input, as it can only be read once.
+// It needs to be applied after CommandWithSubCommandsDecorator.
+type CommandWithVisibilityDecorator struct{}
+
+// visibleSubCommand is a subcommand implementing CommandWithVisibility,
+// collected by CommandWithSubCommandsDecorator.
+type visibleSubCommand struct {
+	cmd     *cobra.Command
+	visible func() bool
+}
+
+// Decorate wraps the help function of commands with conditionally visible
+// subcommands.
+func (CommandWithVisibilityDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, _ Command) error {
+	subs := e.visibleSubCommands
+	if len(subs) == 0 {
+		return nil
+	}
+	parsers := e.helpConfigParsers
+
+	// subcommands are already decorated, so they are hidden only if they
+	// are hidden regardless of their visibility predicate
+	hidden := make([]bool, len(subs))
+	for i, sub := range subs {
+		hidden[i] = sub.cmd.Hidden
+	}
+
+	old := cmd.HelpFunc()
+	cmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		// The help is rendered without executing the command, so the
+		// configuration is parsed here. Errors are ignored, they are reported
+		// once the command is executed.
+		for _, parse := range parsers {
+			_ = parse()
+		}
+
+		for i, sub := range subs {
+			sub.cmd.Hidden = hidden[i] || !sub.visible()
+		}
+		old(cmd, args)
+	})
+	return nil
+}
+
+// -- UNKNOWN SUB COMMAND ------------------------------------------------------
+
+// UnknownSubCommandDecorator is a decorator that reports mistyped subcommands.
+// When a command that has subcommands and is not runnable itself is called
+// with an unknown subcommand, the closest matches (as determined by cobra)
+// and a hint to run --help are written to the error output before the error
+// is returned.
+//
+// Cobra only validates the arguments of runnable commands, so the decorator
+// makes those commands runnable by printing the help when they are invoked
+// without arguments. As a side effect, cmd.Runnable() reports true and the
+// usage includes a line for invoking the command itself. That's why the
+// decorator is not part of DefaultDecorators, enable it using
+// WithUnknownSubCommands. It needs to run after
+// CommandWithSubCommandsDecorator.
+type UnknownSubCommandDecorator struct{}
+
+// WithUnknownSubCommands enables the UnknownSubCommandDecorator.
+func WithUnknownSubCommands() Option {
+	return WithDecorators(UnknownSubCommandDecorator{})
+}
+
+// Decorate validates the arguments of commands with subcommands.
+func (UnknownSubCommandDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, _ Command) error {
+	if !cmd.HasSubCommands() || cmd.Runnable() {
+		return nil
+	}
+
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return nil
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Unknown command %q for %q.\n", args[0], cmd.CommandPath())
+		if !cmd.DisableSuggestions {
+			if cmd.SuggestionsMinimumDistance <= 0 {
+				cmd.SuggestionsMinimumDistance = maxSuggestionDistance
+			}
+			if suggestions := cmd.SuggestionsFor(args[0]); len(suggestions) > 0 {
+				sb.WriteString("\nDid you mean this?\n")
+				for _, s := range suggestions {
+					fmt.Fprintf(&sb, "\t%s\n", s)
+				}
+			}
+		}
+		fmt.Fprintf(&sb, "\nRun '%s --help' for usage.\n", cmd.CommandPath())
+		e.output(cmd).Stderr(sb.String())
+
+		// the hint replaces the usage
+		cmd.SilenceUsage = true
+		return fmt.Errorf("unknown command %q for %q", args[0], cmd.CommandPath())
+	}
+	// the command needs to be runnable for cobra to validate the arguments,
+	// see the documentation of UnknownSubCommandDecorator
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		return cmd.Help()
+	}
+	return nil
+}
+
+// -- REQUIRE SUB COMMAND ------------------------------------------------------
+
+// RequireSubCommandDecorator is a decorator that makes grouping-only commands
+// (commands with subcommands that don't implement CommandWithExecute) fail
+// when they are invoked without a subcommand. The help is printed and an error
+// is returned, so the exit code is non-zero, instead of silently succeeding.
+// Enable it using WithRequiredSubCommands, it needs to run after
+// CommandWithSubCommandsDecorator and UnknownSubCommandDecorator.
+type RequireSubCommandDecorator struct{}
+
+// WithRequiredSubCommands enables the RequireSubCommandDecorator.
+func WithRequiredSubCommands() Option {
+	return WithDecorators(RequireSubCommandDecorator{})
+}
+
+// Decorate makes the command fail if no subcommand is supplied.
+func (RequireSubCommandDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	if _, ok := c.(CommandWithExecute); ok || !cmd.HasSubCommands() {
+		return nil
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if err := cmd.Help(); err != nil {
+			return err
+		}
+		// the help was already printed
+		cmd.SilenceUsage = true
+		return fmt.Errorf("command %q requires a subcommand", cmd.CommandPath())
+	}
+	return nil
+}
+
+// -- DEPRECATED ---------------------------------------------------------------
+
+// CommandWithDeprecated can be implemented by a command to mark it as deprecated
+// and print a message when it is used.
+type CommandWithDeprecated interface {
+	Command
+	// Deprecated returns a message that will be printed when the command is used.
+	Deprecated() string
+}
+
+// CommandWithDeprecatedReplacement can be implemented by a deprecated command
+// to forward its execution to the command that replaces it. This allows
+// renaming a command while keeping the old name working.
+type CommandWithDeprecatedReplacement interface {
+	CommandWithDeprecated
+	// ReplacedBy returns the path of the replacement command relative to the
+	// root command (e.g. "pipelines list"). After the deprecation notice is
+	// printed, the replacement is executed with all arguments and flags
+	// passed to the deprecated command.
+	ReplacedBy() string
+}
+
+// CommandWithDeprecatedDecorator is a decorator that deprecates the command.
+type CommandWithDeprecatedDecorator struct{}
+
+// Decorate deprecates the command.
+func (CommandWithDeprecatedDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithDeprecated)
+	if !ok {
+		return nil
+	}
+
+	cmd.Hidden = true
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[deprecatedAnnotation] = v.Deprecated()
+
+	printNotice := func(cmd *cobra.Command, flags *pflag.FlagSet) {
+		if flags.Changed("json") {
+			return
+		}
+
+		c := cmd.Name()
+		if cmd.HasParent() {
+			c = fmt.Sprintf("%s %s", cmd.Parent().Name(), c)
+		}
+		fmt.Printf("Command %q is deprecated, %s\n", c, v.Deprecated())
+	}
+
+	r, ok := c.(CommandWithDeprecatedReplacement)
+	if !ok {
+		old := cmd.PreRunE
+		cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+			if old != nil {
+				err := old(cmd, args)
+				if err != nil {
+					return err
+				}
+			}
+			printNotice(cmd, cmd.Flags())
+			return nil
+		}
+		return nil
+	}
+
+	// Flags are not parsed, they are passed through to the replacement and
+	// parsed using its flag set.
+	cmd.DisableFlagParsing = true
+	forwardRunE := func(cmd *cobra.Command, args []string) error {
+		target, err := findReplacement(cmd, strings.Fields(r.ReplacedBy()))
+		if err != nil {
+			// The usage of the deprecated command is not helpful.
+			cmd.SilenceUsage = true
+			return err
+		}
+
+		if err := target.ParseFlags(args); err != nil {
+			if errors.Is(err, pflag.ErrHelp) {
+				return target.Help()
+			}
+			return target.FlagErrorFunc()(target, err)
+		}
+		printNotice(cmd, target.Flags())
+
+		err = runReplacement(cmd.Context(), target, target.Flags().Args())
+		if err != nil {
+			cmd.SilenceUsage = true
+		}
+		return err
+	}
+
+	// The forwarding replaces the RunE set by all other decorators, so the
+	// deprecated command itself (e.g. its Execute or confirmation prompt) is
+	// never run.
+	e.finishers = append(e.finishers, func(cmd *cobra.Command) {
+		cmd.RunE = forwardRunE
+	})
+
+	return nil
+}
+
+// findReplacement returns the command found under path in the command tree of
+// cmd.
+func findReplacement(cmd *cobra.Command, path []string) (*cobra.Command, error) {
+	root := cmd.Root()
+	target, rest, err := root.Find(path)
+	if err != nil || len(rest) > 0 || target == root || target == cmd {
+		return nil, fmt.Errorf("replacement command %q not found", strings.Join(path, " "))
+	}
+	return target, nil
+}
+
+// runReplacement validates the parsed flags and args of the replacement
+// command and runs its PreRunE, RunE and PostRunE. The command is not executed
+// through the root command, so the args of the root command are left intact.
+func runReplacement(ctx context.Context, target *cobra.Command, args []string) error {
+	if err := target.ValidateArgs(args); err != nil {
+		return err //nolint:wrapcheck // error is returned as is, it comes from the replacement command
+	}
+	if err := target.ValidateRequiredFlags(); err != nil {
+		return err //nolint:wrapcheck // error is returned as is, it comes from the replacement command
+	}
+	if err := target.ValidateFlagGroups(); err != nil {
+		return err //nolint:wrapcheck // error is returned as is, it comes from the replacement command
+	}
+
+	target.SetContext(ctx)
+	for _, run := range []func(*cobra.Command, []string) error{target.PreRunE, target.RunE, target.PostRunE} {
+		if run == nil {
+			continue
+		}
+		if err := run(target, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// -- ARGS ---------------------------------------------------------------------
+
+// CommandWithArgs can be implemented by a command to parse arguments.
+type CommandWithArgs interface {
+	Command
+	// Args is meant to parse arguments after the command name.
+	Args([]string) error
+}
+
+// CommandWithDefaultArgs can be implemented by a command to supply default
+// arguments used when the user doesn't provide any (e.g. ".").
+type CommandWithDefaultArgs interface {
+	CommandWithArgs
+	// DefaultArgs returns the arguments passed to Args when no arguments are
+	// supplied.
+	DefaultArgs() []string
+}
+
+// CommandWithArgsDecorator is a decorator that provides the command arguments.
+type CommandWithArgsDecorator struct{}
+
+// Decorate provides the command arguments.
+func (CommandWithArgsDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithArgs)
+	if !ok {
+		return nil
+	}
+
+	old := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if old != nil {
+			err := old(cmd, args)
+			if err != nil {
+				return err
+			}
+		}
+		if d, ok := v.(CommandWithDefaultArgs); ok && len(args) == 0 {
+			args = d.DefaultArgs()
+		}
+		return v.Args(args)
+	}
+	return nil
+}
+
+// CommandWithArgCount can be implemented by a command to enforce the number of
+// positional arguments with a friendly error message (e.g. "expected <source>
+// <dest>") instead of the generic cobra error.
+type CommandWithArgCount interface {
+	Command
+	// ArgCount returns the minimum and maximum number of positional
+	// arguments. A negative maximum means there is no upper bound.
+	ArgCount() (min, max int)
+	// ArgCountMessage returns a text/template used to render the error when
+	// the number of arguments is out of range. The template can refer to
+	// {{.Command}}, {{.Min}}, {{.Max}} and {{.Got}}.
+	ArgCountMessage() string
+}
+
+// CommandWithArgCountDecorator is a decorator that validates the number of
+// positional arguments.
+type CommandWithArgCountDecorator struct{}
+
+// argCountMessageData is the data passed to the template returned by
+// CommandWithArgCount.ArgCountMessage.
+type argCountMessageData struct {
+	Command string
+	Min     int
+	Max     int
+	Got     int
+}
+
+// Decorate sets the cobra.Command.Args function validating the number of
+// positional arguments. For commands implementing CommandWithDefaultArgs, the
+// default arguments are validated if no arguments are supplied.
+func (CommandWithArgCountDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithArgCount)
+	if !ok {
+		return nil
+	}
+
+	minArgs, maxArgs := v.ArgCount()
+	if maxArgs >= 0 && maxArgs < minArgs {
+		return fmt.Errorf("invalid argument count range: max %d is lower than min %d", maxArgs, minArgs)
+	}
+	tmpl, err := template.New("args").Parse(v.ArgCountMessage())
+	if err != nil {
+		return fmt.Errorf("invalid argument count message: %w", err)
+	}
+
+	old := cmd.Args
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		// default args are passed to Args later, they need to be counted
+		if d, ok := c.(CommandWithDefaultArgs); ok && len(args) == 0 {
+			args = d.DefaultArgs()
+		}
+		if len(args) < minArgs || (maxArgs >= 0 && len(args) > maxArgs) {
+			var sb strings.Builder
+			err := tmpl.Execute(&sb, argCountMessageData{
+				Command: cmd.CommandPath(),
+				Min:     minArgs,
+				Max:     maxArgs,
+				Got:     len(args),
+			})
+			if err != nil {
+				return fmt.Errorf("error rendering argument count message: %w", err)
+			}
+			return errors.New(sb.String())
+		}
+		if old != nil {
+			return old(cmd, args)
+		}
+		return nil
+	}
+	return nil
+}
+
+// ArgFlagDependency declares that a positional argument and a flag need to be
+// supplied together: if one of them is supplied, the other one is required.
+type ArgFlagDependency struct {
+	// Arg is the zero-based index of the positional argument.
+	Arg int
+	// Flag is the long name of the flag.
+	Flag string
+}
+
+// CommandWithArgFlagDependencies can be implemented by a command to declare
+// positional arguments and flags that need to be supplied together (e.g. a
+// name and --value).
+type CommandWithArgFlagDependencies interface {
+	Command
+	// ArgFlagDependencies returns the dependencies between positional
+	// arguments and flags.
+	ArgFlagDependencies() []ArgFlagDependency
+}
+
+// CommandWithArgFlagDependenciesDecorator is a decorator that enforces the
+// dependencies between positional arguments and flags.
+type CommandWithArgFlagDependenciesDecorator struct{}
+
+// Decorate checks the dependencies between positional arguments and flags
+// before the command is executed.
+func (CommandWithArgFlagDependenciesDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithArgFlagDependencies)
+	if !ok {
+		return nil
+	}
+
+	deps := v.ArgFlagDependencies()
+	old := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if old != nil {
+			err := old(cmd, args)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, dep := range deps {
+			argSet := dep.Arg < len(args)
+			flagSet := cmd.Flags().Changed(dep.Flag)
+			switch {
+			case argSet && !flagSet:
+				return fmt.Errorf("argument %d (%q) requires flag --%s", dep.Arg+1, args[dep.Arg], dep.Flag)
+			case !argSet && flagSet:
+				return fmt.Errorf("flag --%s requires argument %d", dep.Flag, dep.Arg+1)
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// -- CONFIRM ------------------------------------------------------------------
+
+// CommandWithConfirm can be implemented by a command to require confirmation
+// before execution. The user will be prompted to enter a specific value.
+// If the value matches, the command will be executed, otherwise it will be
+// aborted.
+type CommandWithConfirm interface {
+	Command
+	// ValueToConfirm adds a prompt before the command is executed where the
+	// user is asked to write the exact value as wantInput. If the user input
+	// matches the command will be executed, otherwise processing will be
+	// aborted.
+	ValueToConfirm(context.Context) (wantInput string)
+}
+
+// CommandWithConfirmDecorator is a decorator that sets up a confirmation prompt
+// before executing the command. By default, the input needs to match the value
+// exactly.
+type CommandWithConfirmDecorator struct {
+	// TrimSpace removes leading and trailing whitespace from the input before
+	// comparing it.
+	TrimSpace bool
+	// CaseInsensitive compares the input and the value case-insensitively.
+	CaseInsensitive bool
+}
+
+// Decorate sets up a confirmation prompt before executing the command.
+func (d CommandWithConfirmDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithConfirm)
+	if !ok {
+		return nil
+	}
+
+	if err := addSkipConfirmFlags(cmd); err != nil {
+		return err
+	}
+
+	old := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if old != nil {
+			err := old(cmd, args)
+			if err != nil {
+				return err
+			}
+		}
+
+		if skipConfirm(cmd) {
+			return nil
+		}
+		return d.confirm(cmd, "", v.ValueToConfirm(cmd.Context()))
+	}
+
+	return nil
+}
+
+// confirm prints the message followed by a prompt asking the user to type
+// wantInput and returns an error if the input doesn't match.
+func (d CommandWithConfirmDecorator) confirm(cmd *cobra.Command, msg, wantInput string) error {
+	reader := bufio.NewReader(cmd.InOrStdin())
+	fmt.Fprintf(cmd.OutOrStdout(), "%sTo proceed, type %q or re-run this command with --force\n▸ ", msg, wantInput)
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read user input: %w", err)
+	}
+
+	if !d.matches(strings.TrimRight(input, "\r\n"), wantInput) {
+		return errors.New("action aborted")
+	}
+
+	return nil
+}
+
+// matches reports whether the input matches the wanted input, taking into
+// account the options TrimSpace and CaseInsensitive.
+func (d CommandWithConfirmDecorator) matches(input, want string) bool {
+	if d.TrimSpace {
+		input = strings.TrimSpace(input)
+	}
+	if d.CaseInsensitive {
+		return strings.EqualFold(input, want)
+	}
+	return input == want
+}
+
+// addSkipConfirmFlags adds the flags --force and the hidden --yolo, which skip
+// the confirmation prompt. The flags are only added once, they can be shared
+// by multiple confirmation decorators.
+func addSkipConfirmFlags(cmd *cobra.Command) error {
+	if cmd.Flags().Lookup("force") != nil {
+		return nil
+	}
+	cmd.Flags().BoolP("force", "f", false, "skip confirmation prompt")
+	cmd.Flags().Bool("yolo", false, "skip confirmation prompt")
+	err := cmd.Flags().MarkHidden("yolo")
+	if err != nil {
+		return fmt.Errorf("could not mark flag hidden: %w", err)
+	}
+	return nil
+}
+
+type confirmedCtxKey struct{}
+
+// contextWithConfirmed returns a copy of the context marking the execution as
+// confirmed, confirmation prompts are skipped.
+func contextWithConfirmed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, confirmedCtxKey{}, true)
+}
+
+// skipConfirm reports whether the confirmation prompt is skipped using --force
+// (or --yolo 😜) or because the execution was already confirmed (e.g. in the
+// first run of a watched command).
+func skipConfirm(cmd *cobra.Command) bool {
+	if confirmed, _ := cmd.Context().Value(confirmedCtxKey{}).(bool); confirmed {
+		return true
+	}
+	force, _ := cmd.Flags().GetBool("force")
+	yolo, _ := cmd.Flags().GetBool("yolo")
+	return force || yolo
+}
+
+// -- PROMPT -------------------------------------------------------------------
+
+// CommandWithPrompt can be implemented by a command to require confirmation
+// before execution. The user will be prompted to answer Y/N to proceed.
+type CommandWithPrompt interface {
+	Command
+
+	// Prompt adds a prompt before the command is executed where the user is
+	// asked to answer Y/N to proceed. It returns the message to be printed and
+	// a boolean indicating if the prompt was successfully processed.
+	Prompt() (message string, ok bool)
+	// SkipPrompt will return logic around when to skip prompt (e.g.: when all
+	// flags and arguments are specified).
+	SkipPrompt() bool
+}
+
+// CommandWithPromptDecorator is a decorator that sets up a confirmation prompt
+// before executing the command.
+type CommandWithPromptDecorator struct{}
+
+// Decorate sets up a confirmation prompt before executing the command.
+func (CommandWithPromptDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithPrompt)
+	if !ok {
+		return nil
+	}
+
+	if err := addSkipConfirmFlags(cmd); err != nil {
+		return err
+	}
+
+	old := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if old != nil {
+			err := old(cmd, args)
+			if err != nil {
+				return err
+			}
+		}
+
+		if skipConfirm(cmd) || v.SkipPrompt() {
+			return nil
+		}
+
+		msg, ok := v.Prompt()
+
+		if !ok {
+			fmt.Println(msg)
+			os.Exit(1)
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+// -- AFFECTED COUNT -----------------------------------------------------------
+
+// CommandWithAffectedCount can be implemented by a command operating on
+// multiple resources to require confirmation when it would affect more
+// resources than a threshold. The user will be prompted to enter the number of
+// affected resources. Commands implementing CommandWithConfirm are already
+// confirmed and are not prompted again.
+type CommandWithAffectedCount interface {
+	Command
+	// AffectedCount returns the number of resources the command would affect.
+	AffectedCount(context.Context) (int, error)
+	// AffectedCountThreshold returns the number of resources that can be
+	// affected without confirmation.
+	AffectedCountThreshold() int
+}
+
+// CommandWithAffectedCountDecorator is a decorator that sets up a confirmation
+// prompt before executing a command that affects many resources. The input is
+// compared using the options of CommandWithConfirmDecorator.
+type CommandWithAffectedCountDecorator struct{}
+
+// Decorate sets up a confirmation prompt before executing the command.
+func (CommandWithAffectedCountDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithAffectedCount)
+	if !ok {
+		return nil
+	}
+	if _, ok := c.(CommandWithConfirm); ok {
+		return nil
+	}
+
+	var confirm CommandWithConfirmDecorator
+	for _, dec := range e.Decorators {
+		if v, ok := dec.(CommandWithConfirmDecorator); ok {
+			confirm = v
+		}
+	}
+
+	if err := addSkipConfirmFlags(cmd); err != nil {
+		return err
+	}
+
+	old := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if old != nil {
+			err := old(cmd, args)
+			if err != nil {
+				return err
+			}
+		}
+
+		if skipConfirm(cmd) {
+			return nil
+		}
+
+		count, err := v.AffectedCount(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to determine affected resources: %w", err)
+		}
+		if count <= v.AffectedCountThreshold() {
+			return nil
+		}
+
+		msg := fmt.Sprintf("This command affects %d resources. ", count)
+		return confirm.confirm(cmd, msg, strconv.Itoa(count))
+	}
+
+	return nil
+}
+
+// -- EXECUTE ------------------------------------------------------------------
+
+// CommandWithExecute can be implemented by a command to provide an execution
+// function.
+type CommandWithExecute interface {
+	Command
+	// Execute is the actual work function. Most commands will implement this.
+	Execute(ctx context.Context) error
+}
+
+// CommandWithExecuteDecorator is a decorator that sets the command execution.
+type CommandWithExecuteDecorator struct{}
+
+// Decorate sets the command execution.
+func (CommandWithExecuteDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithExecute)
+	if !ok {
+		return nil
+	}
+
+	old := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if old != nil {
+			err := old(cmd, args)
+			if err != nil {
+				return err
+			}
+		}
+
+		ctx := contextWithCobraCommand(cmd.Context(), cmd)
+		return v.Execute(ctx)
+	}
+
+	return nil
+}
+
+// -- BATCH --------------------------------------------------------------------
+
+// CommandWithBatch can be implemented by a command processing multiple items
+// (e.g. deleting multiple pipelines). By default, processing stops at the first
+// item that fails, with the flag --continue-on-error the remaining items are
+// processed and all errors are returned at the end.
+type CommandWithBatch interface {
 	Command
-	// ValueToConfirm adds a prompt before the command is executed where the
-	// user is asked to write the exact value as wantInput. If the user input
-	// matches the command will be executed, otherwise processing will be
-	// aborted.
-	ValueToConfirm(context.Context) (wantInput string)
+	// BatchItems returns the items processed by the command.
+	BatchItems(ctx context.Context) ([]string, error)
+	// ProcessItem processes a single item.
+	ProcessItem(ctx context.Context, item string) error
 }
 
-// CommandWithConfirmDecorator is a decorator that sets up a confirmation prompt
-// before executing the command.
-type CommandWithConfirmDecorator struct{}
+// CommandWithBatchDecorator is a decorator that registers the flag
+// --continue-on-error and processes the items of the command. After the items
+// are processed, a summary is written to the error output. The returned error
+// wraps the errors of the failed items, so that the ErrorClassifier can pick
+// the matching exit code.
+type CommandWithBatchDecorator struct{}
 
-// Decorate sets up a confirmation prompt before executing the command.
-func (CommandWithConfirmDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
-	v, ok := c.(CommandWithConfirm)
+// Decorate sets up processing the items of the command.
+func (CommandWithBatchDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithBatch)
 	if !ok {
 		return nil
 	}
 
-	var (
-		force bool
-		yolo  bool
-	)
-	cmd.Flags().BoolVarP(&force, "force", "f", false, "skip confirmation prompt")
-	cmd.Flags().BoolVarP(&yolo, "yolo", "", false, "skip confirmation prompt")
-	err := cmd.Flags().MarkHidden("yolo")
-	if err != nil {
-		return fmt.Errorf("could not mark flag hidden: %w", err)
-	}
+	var continueOnError bool
+	cmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "process the remaining items if an item fails")
 
 	old := cmd.RunE
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
@@ -519,66 +1881,252 @@ func (CommandWithConfirmDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Co
 			}
 		}
 
-		// do not prompt for confirmation when --force (or --yolo 😜) is set
-		if force || yolo {
+		ctx := contextWithCobraCommand(cmd.Context(), cmd)
+		items, err := v.BatchItems(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to determine items: %w", err)
+		}
+
+		var (
+			succeeded int
+			errs      []error
+		)
+		for _, item := range items {
+			if ctx.Err() != nil {
+				break
+			}
+			if err := v.ProcessItem(ctx, item); err != nil {
+				errs = append(errs, fmt.Errorf("item %q: %w", item, err))
+				if !continueOnError {
+					break
+				}
+				continue
+			}
+			succeeded++
+		}
+
+		processed := succeeded + len(errs)
+		e.output(cmd).Stderr(fmt.Sprintf(
+			"Processed %d of %d items: %d succeeded, %d failed\n",
+			processed, len(items), succeeded, len(errs),
+		))
+
+		switch {
+		case len(errs) == 0 && processed < len(items):
+			// processing stops early without errors only if the context is
+			// cancelled
+			return ctx.Err()
+		case len(errs) == 0:
 			return nil
+		case !continueOnError:
+			return errs[0]
+		default:
+			return fmt.Errorf("%d of %d items failed: %w", len(errs), len(items), errors.Join(errs...))
 		}
+	}
+
+	return nil
+}
 
-		wantInput := v.ValueToConfirm(cmd.Context())
+// -- TABLE --------------------------------------------------------------------
+
+// CommandWithTable can be implemented by a command that outputs a table (e.g.
+// a list command). The table is rendered to the output of the command, users
+// can select the displayed columns using the flag --columns and sort the rows
+// using the flag --sort-by <column>[:asc|desc].
+type CommandWithTable interface {
+	Command
+	// Table returns the table to be rendered.
+	Table(ctx context.Context) (Table, error)
+}
+
+// CommandWithTableDecorator is a decorator that renders the table returned by
+// the command.
+type CommandWithTableDecorator struct{}
+
+// Decorate registers the flags --columns and --sort-by and renders the table
+// when the command is executed.
+func (CommandWithTableDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithTable)
+	if !ok {
+		return nil
+	}
+
+	var (
+		columns []string
+		sortBy  string
+	)
+	cmd.Flags().StringSliceVar(&columns, "columns", nil, "comma separated list of columns to display")
+	cmd.Flags().StringVar(&sortBy, "sort-by", "", "sort rows by column, optionally followed by the direction (e.g. name:desc)")
+
+	old := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if old != nil {
+			err := old(cmd, args)
+			if err != nil {
+				return err
+			}
+		}
 
-		reader := bufio.NewReader(os.Stdin)
-		fmt.Printf("To proceed, type %q or re-run this command with --force\n▸ ", wantInput)
-		input, err := reader.ReadString('\n')
+		ctx := contextWithCobraCommand(cmd.Context(), cmd)
+		table, err := v.Table(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to read user input: %w", err)
+			return err
 		}
 
-		if wantInput != strings.TrimRight(input, "\r\n") {
-			return errors.New("action aborted")
+		// rows are sorted before selecting columns, so they can be sorted by a
+		// column that is not displayed
+		if sortBy != "" {
+			column, desc, err := parseSortBy(sortBy)
+			if err != nil {
+				return fmt.Errorf("invalid flag --sort-by: %w", err)
+			}
+			table, err = table.SortBy(column, desc)
+			if err != nil {
+				return fmt.Errorf("invalid flag --sort-by: %w", err)
+			}
 		}
 
-		return nil
+		if len(columns) > 0 {
+			table, err = table.SelectColumns(columns)
+			if err != nil {
+				return fmt.Errorf("invalid flag --columns: %w", err)
+			}
+		}
+		return table.Render(cmd.OutOrStdout())
 	}
 
 	return nil
 }
 
-// -- PROMPT -------------------------------------------------------------------
+// -- TEMPLATE -----------------------------------------------------------------
 
-// CommandWithPrompt can be implemented by a command to require confirmation
-// before execution. The user will be prompted to answer Y/N to proceed.
-type CommandWithPrompt interface {
+// CommandWithTemplate can be implemented by a command that outputs a result
+// object. The result is rendered using a Go text/template, which users can
+// replace using the flag --template (e.g. --template '{{.Name}}') if it is
+// enabled with WithTemplateFlag.
+type CommandWithTemplate interface {
 	Command
+	// TemplateData returns the object passed to the template.
+	TemplateData(ctx context.Context) (any, error)
+	// DefaultTemplate returns the template used if the user doesn't supply
+	// one.
+	DefaultTemplate() string
+}
 
-	// Prompt adds a prompt before the command is executed where the user is
-	// asked to answer Y/N to proceed. It returns the message to be printed and
-	// a boolean indicating if the prompt was successfully processed.
-	Prompt() (message string, ok bool)
-	// SkipPrompt will return logic around when to skip prompt (e.g.: when all
-	// flags and arguments are specified).
-	SkipPrompt() bool
+// CommandWithTemplateDecorator is a decorator that renders the result of the
+// command using a template.
+type CommandWithTemplateDecorator struct {
+	// Flag is the name of a flag that accepts a template replacing the
+	// default template of the command (e.g. "template"). If empty, the flag is
+	// not registered.
+	Flag string
 }
 
-// CommandWithPromptDecorator is a decorator that sets up a confirmation prompt
-// before executing the command.
-type CommandWithPromptDecorator struct{}
+// WithTemplateFlag enables the flag --template, allowing users to render the
+// result of commands implementing CommandWithTemplate using their own template.
+func WithTemplateFlag() Option {
+	return WithDecorators(CommandWithTemplateDecorator{Flag: "template"})
+}
 
-// Decorate sets up a confirmation prompt before executing the command.
-func (CommandWithPromptDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
-	v, ok := c.(CommandWithPrompt)
+// Decorate registers the template flag, validates the template before the
+// command is executed and renders the result after executing the command.
+func (d CommandWithTemplateDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithTemplate)
 	if !ok {
 		return nil
 	}
 
+	tmpl, err := template.New("default").Parse(v.DefaultTemplate())
+	if err != nil {
+		return fmt.Errorf("invalid default template: %w", err)
+	}
+
 	var (
-		force bool
-		yolo  bool
+		text   string
+		custom *template.Template
 	)
-	cmd.Flags().BoolVarP(&force, "force", "f", false, "skip confirmation prompt")
-	cmd.Flags().BoolVarP(&yolo, "yolo", "", false, "skip confirmation prompt")
-	err := cmd.Flags().MarkHidden("yolo")
-	if err != nil {
-		return fmt.Errorf("could not mark flag hidden: %w", err)
+	if d.Flag != "" {
+		cmd.Flags().StringVar(&text, d.Flag, "", "format the output using a Go template (e.g. '{{.Name}}')")
+	}
+
+	oldPreRunE := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if oldPreRunE != nil {
+			err := oldPreRunE(cmd, args)
+			if err != nil {
+				return err
+			}
+		}
+		custom = nil
+		if text == "" {
+			return nil
+		}
+		// validate the template before the command is executed
+		t, err := template.New(d.Flag).Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid flag --%s: %w", d.Flag, err)
+		}
+		custom = t
+		return nil
+	}
+
+	oldRunE := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if oldRunE != nil {
+			err := oldRunE(cmd, args)
+			if err != nil {
+				return err
+			}
+		}
+
+		ctx := contextWithCobraCommand(cmd.Context(), cmd)
+		data, err := v.TemplateData(ctx)
+		if err != nil {
+			return err
+		}
+
+		t := tmpl
+		if custom != nil {
+			t = custom
+		}
+		var sb strings.Builder
+		if err := t.Execute(&sb, data); err != nil {
+			return fmt.Errorf("error rendering template: %w", err)
+		}
+		out := sb.String()
+		if !strings.HasSuffix(out, "\n") {
+			out += "\n"
+		}
+		_, err = fmt.Fprint(cmd.OutOrStdout(), out)
+		return err
+	}
+
+	return nil
+}
+
+// -- NEXT STEPS ---------------------------------------------------------------
+
+// CommandWithNextSteps can be implemented by a command to suggest what the user
+// can do next (e.g. the command to start a pipeline after creating it).
+type CommandWithNextSteps interface {
+	CommandWithExecute
+	// NextSteps returns the message printed after the command was executed
+	// successfully. If empty, nothing is printed.
+	NextSteps() string
+}
+
+// CommandWithNextStepsDecorator is a decorator that prints the next steps to
+// the error output after the command was executed successfully. The message is
+// not printed if the output format is JSON or YAML, to keep the output
+// machine-readable.
+type CommandWithNextStepsDecorator struct{}
+
+// Decorate prints the next steps after executing the command.
+func (CommandWithNextStepsDecorator) Decorate(e *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithNextSteps)
+	if !ok {
+		return nil
 	}
 
 	old := cmd.RunE
@@ -590,56 +2138,385 @@ func (CommandWithPromptDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Com
 			}
 		}
 
-		// do not prompt for confirmation when --force (or --yolo 😜) is set
-		if force || yolo || v.SkipPrompt() {
+		switch outputFormatFromCommand(cmd) {
+		case OutputFormatJSON, OutputFormatYAML:
 			return nil
 		}
 
-		msg, ok := v.Prompt()
-
-		if !ok {
-			fmt.Println(msg)
-			os.Exit(1)
+		msg := v.NextSteps()
+		if msg == "" {
+			return nil
 		}
-
+		if !strings.HasSuffix(msg, "\n") {
+			msg += "\n"
+		}
+		e.output(cmd).Stderr(msg)
 		return nil
 	}
 
 	return nil
 }
 
-// -- EXECUTE ------------------------------------------------------------------
+// -- WATCH --------------------------------------------------------------------
 
-// CommandWithExecute can be implemented by a command to provide an execution
-// function.
-type CommandWithExecute interface {
-	Command
-	// Execute is the actual work function. Most commands will implement this.
-	Execute(ctx context.Context) error
+// CommandWithWatch can be implemented by a command to allow re-running it
+// periodically using the flag --watch <interval>, similar to watch(1). This is
+// useful for commands that only read and display state.
+type CommandWithWatch interface {
+	CommandWithExecute
+	// MinWatchInterval returns the smallest interval accepted by --watch.
+	MinWatchInterval() time.Duration
 }
 
-// CommandWithExecuteDecorator is a decorator that sets the command execution.
-type CommandWithExecuteDecorator struct{}
+// CommandWithWatchDecorator is a decorator that registers the flag --watch and
+// re-executes the command on the specified interval until the context is
+// cancelled. The screen is cleared between executions if the output is a
+// terminal. Confirmation prompts are only shown before the first execution.
+type CommandWithWatchDecorator struct {
+	// After is used to wait for the interval between executions. If nil,
+	// time.After is used.
+	After func(time.Duration) <-chan time.Time
+}
 
-// Decorate sets the command execution.
-func (CommandWithExecuteDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
-	v, ok := c.(CommandWithExecute)
+// Decorate sets up the periodic execution of the command.
+func (d CommandWithWatchDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, c Command) error {
+	v, ok := c.(CommandWithWatch)
 	if !ok {
 		return nil
 	}
 
+	var interval time.Duration
+	cmd.Flags().DurationVar(&interval, "watch", 0, "re-run the command periodically on the specified interval")
+
+	after := d.After
+	if after == nil {
+		after = time.After
+	}
+
 	old := cmd.RunE
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		if old != nil {
-			err := old(cmd, args)
-			if err != nil {
+		if !cmd.Flags().Changed("watch") {
+			return old(cmd, args)
+		}
+		if interval <= 0 {
+			return errors.New("watch interval must be positive")
+		}
+		if minInterval := v.MinWatchInterval(); interval < minInterval {
+			return fmt.Errorf("watch interval must be at least %s", minInterval)
+		}
+
+		out := cmd.OutOrStdout()
+		for {
+			if isTerminal(out) {
+				fmt.Fprint(out, clearScreen)
+			}
+			if err := old(cmd, args); err != nil {
 				return err
 			}
+			// the user confirmed the first execution, the following ones
+			// are not confirmed again
+			cmd.SetContext(contextWithConfirmed(cmd.Context()))
+
+			select {
+			case <-cmd.Context().Done():
+				return nil
+			case <-after(interval):
+			}
 		}
+	}
 
-		ctx := contextWithCobraCommand(cmd.Context(), cmd)
-		return v.Execute(ctx)
+	return nil
+}
+
+// -- COMPLETE FLAGS -----------------------------------------------------------
+
+// completeFlagsCommandName is the name of the hidden command added by
+// CompleteFlagsDecorator.
+const completeFlagsCommandName = "__complete-flags"
+
+// CompleteFlagsDecorator is a decorator that adds the hidden command
+// "__complete-flags" to the root command. The command prints all flags of the
+// command found under the supplied path (e.g. "mycli __complete-flags sub
+// cmd"), one flag per line, with tab separated columns containing the long
+// name, short name, type and usage of the flag. This allows external
+// completion engines to complete flags.
+//
+// The decorator needs to run after CommandWithSubCommandsDecorator and only
+// adds the command to root commands that have subcommands. Enable it using
+// WithDecorators(CompleteFlagsDecorator{}).
+type CompleteFlagsDecorator struct{}
+
+// Decorate adds the command "__complete-flags".
+func (CompleteFlagsDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, _ Command) error {
+	if !cmd.HasSubCommands() {
+		return nil
+	}
+
+	addRootCommand(cmd, &cobra.Command{
+		Use:    completeFlagsCommandName + " [COMMAND]...",
+		Short:  "Print the flags of a command in a machine-readable format",
+		Hidden: true,
+		// All arguments are treated as the path of the command.
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target, _, err := cmd.Root().Find(args)
+			if err != nil {
+				return fmt.Errorf("could not find command: %w", err)
+			}
+			target.InitDefaultHelpFlag()
+
+			out := cmd.OutOrStdout()
+			printFlag := func(f *pflag.Flag) {
+				if f.Hidden {
+					return
+				}
+				var short string
+				if f.Shorthand != "" {
+					short = "-" + f.Shorthand
+				}
+				fmt.Fprintf(out, "--%s\t%s\t%s\t%s\n", f.Name, short, f.Value.Type(), f.Usage)
+			}
+			target.LocalFlags().VisitAll(printFlag)
+			target.InheritedFlags().VisitAll(printFlag)
+			return nil
+		},
+	})
+
+	return nil
+}
+
+// addRootCommand adds the command sub to cmd and removes commands with the same
+// name from the children of cmd. Subcommands are built before their parent, so
+// at decoration time we don't know if cmd is the root command. Decorators
+// adding a command only to the root add it to every parent, in the end only the
+// root keeps it.
+func addRootCommand(cmd *cobra.Command, sub *cobra.Command) {
+	for _, child := range cmd.Commands() {
+		var remove []*cobra.Command
+		for _, c := range child.Commands() {
+			if c.Name() == sub.Name() {
+				remove = append(remove, c)
+			}
+		}
+		child.RemoveCommand(remove...)
+	}
+	cmd.AddCommand(sub)
+}
+
+// -- DEPRECATION REPORT -------------------------------------------------------
+
+// deprecatedAnnotation is the annotation of deprecated commands containing the
+// deprecation message.
+const deprecatedAnnotation = "ecdysis_deprecated"
+
+// deprecationsCommandName is the name of the hidden command added by
+// DeprecationReportDecorator.
+const deprecationsCommandName = "__deprecations"
+
+// DeprecationReportDecorator is a decorator that adds the hidden command
+// "__deprecations" to the root command. The command lists all deprecated
+// commands and flags in the command tree along with their deprecation
+// messages (see DeprecationReport), as a table or as JSON when used with
+// --output json.
+//
+// The decorator needs to run after CommandWithSubCommandsDecorator and only
+// adds the command to root commands that have subcommands. Enable it using
+// WithDecorators(DeprecationReportDecorator{}).
+type DeprecationReportDecorator struct{}
+
+// Decorate adds the command "__deprecations".
+func (DeprecationReportDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, _ Command) error {
+	if !cmd.HasSubCommands() {
+		return nil
+	}
+
+	report := &cobra.Command{
+		Use:    deprecationsCommandName,
+		Short:  "List deprecated commands and flags",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			items := DeprecationReport(cmd.Root())
+			if outputFormatFromCommand(cmd) == OutputFormatJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(items) //nolint:wrapcheck // no additional context to add
+			}
+
+			table := Table{Headers: []string{"COMMAND", "FLAG", "MESSAGE"}}
+			for _, item := range items {
+				var flag string
+				if item.Flag != "" {
+					flag = "--" + item.Flag
+				}
+				table.Rows = append(table.Rows, []string{item.Command, flag, item.Message})
+			}
+			return table.Render(cmd.OutOrStdout())
+		},
+	}
+	report.Flags().String(outputFlagName, string(OutputFormatText), "output format (text or json)")
+	addRootCommand(cmd, report)
+
+	return nil
+}
+
+// DeprecatedItem is a deprecated command or flag.
+type DeprecatedItem struct {
+	// Command is the path of the deprecated command or the command the
+	// deprecated flag belongs to.
+	Command string `json:"command"`
+	// Flag is the name of the deprecated flag, empty if the command is
+	// deprecated.
+	Flag string `json:"flag,omitempty"`
+	// Message is the deprecation message.
+	Message string `json:"message"`
+}
+
+// DeprecationReport walks the command tree starting at root and returns all
+// deprecated commands (see CommandWithDeprecated) and flags (see
+// Flag.Deprecated).
+func DeprecationReport(root *cobra.Command) []DeprecatedItem {
+	var items []DeprecatedItem
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if msg, ok := cmd.Annotations[deprecatedAnnotation]; ok {
+			items = append(items, DeprecatedItem{Command: cmd.CommandPath(), Message: msg})
+		} else if cmd.Deprecated != "" {
+			items = append(items, DeprecatedItem{Command: cmd.CommandPath(), Message: cmd.Deprecated})
+		}
+		cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+			if f.Deprecated != "" {
+				items = append(items, DeprecatedItem{Command: cmd.CommandPath(), Flag: f.Name, Message: f.Deprecated})
+			}
+		})
+		for _, sub := range cmd.Commands() {
+			walk(sub)
+		}
+	}
+	walk(root)
+	return items
+}
+
+// -- JSON HELP ----------------------------------------------------------------
+
+// JSONHelpDecorator is a decorator that makes the help output machine-readable.
+// It registers the flag --output (unless the command already defines it) and
+// prints the help as JSON when --help is combined with --output json. The JSON
+// document follows the same schema as the one produced by ExportSpec. In all
+// other cases the regular help text is printed.
+//
+// The decorator is not part of DefaultDecorators, enable it using
+// WithDecorators(JSONHelpDecorator{}).
+type JSONHelpDecorator struct{}
+
+// Decorate sets up the JSON help output.
+func (JSONHelpDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, _ Command) error {
+	if cmd.Flags().Lookup(outputFlagName) == nil && cmd.PersistentFlags().Lookup(outputFlagName) == nil {
+		cmd.Flags().String(outputFlagName, string(OutputFormatText), "output format (text, json)")
 	}
 
+	textHelp := cmd.HelpFunc()
+	cmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		if outputFormatFromCommand(cmd) != OutputFormatJSON {
+			textHelp(cmd, args)
+			return
+		}
+
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		err := enc.Encode(Spec{
+			Version: SpecVersion,
+			Command: newHelpSpec(cmd),
+		})
+		if err != nil {
+			cmd.PrintErrln("Error:", err)
+		}
+	})
+
+	return nil
+}
+
+// -- INHERITED FLAGS HELP -----------------------------------------------------
+
+// InheritedFlagsHelpDecorator is a decorator that changes the usage template of
+// commands, so that the help lists the flags defined on the command under
+// "Flags" and the persistent flags inherited from parent commands under
+// "Global Flags (inherited)".
+//
+// The decorator is not part of DefaultDecorators, enable it using
+// WithInheritedFlagsHelp.
+type InheritedFlagsHelpDecorator struct{}
+
+// WithInheritedFlagsHelp enables the InheritedFlagsHelpDecorator.
+func WithInheritedFlagsHelp() Option {
+	return WithDecorators(InheritedFlagsHelpDecorator{})
+}
+
+// inheritedFlagsUsageTemplate is the default usage template of cobra, with the
+// heading of the inherited flags changed to "Global Flags (inherited)".
+const inheritedFlagsUsageTemplate = `Usage:{{if .Runnable}}
+  {{.UseLine}}{{end}}{{if .HasAvailableSubCommands}}
+  {{.CommandPath}} [command]{{end}}{{if gt (len .Aliases) 0}}
+
+Aliases:
+  {{.NameAndAliases}}{{end}}{{if .HasExample}}
+
+Examples:
+{{.Example}}{{end}}{{if .HasAvailableSubCommands}}{{$cmds := .Commands}}{{if eq (len .Groups) 0}}
+
+Available Commands:{{range $cmds}}{{if (or .IsAvailableCommand (eq .Name "help"))}}
+  {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{else}}{{range $group := .Groups}}
+
+{{.Title}}{{range $cmds}}{{if (and (eq .GroupID $group.ID) (or .IsAvailableCommand (eq .Name "help")))}}
+  {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if not .AllChildCommandsHaveGroup}}
+
+Additional Commands:{{range $cmds}}{{if (and (eq .GroupID "") (or .IsAvailableCommand (eq .Name "help")))}}
+  {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
+
+Flags:
+{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}{{if .HasAvailableInheritedFlags}}
+
+Global Flags (inherited):
+{{.InheritedFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}{{if .HasHelpSubCommands}}
+
+Additional help topics:{{range .Commands}}{{if .IsAdditionalHelpTopicCommand}}
+  {{rpad .CommandPath .CommandPathPadding}} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableSubCommands}}
+
+Use "{{.CommandPath}} [command] --help" for more information about a command.{{end}}
+`
+
+// Decorate sets the usage template of the command.
+func (InheritedFlagsHelpDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, _ Command) error {
+	cmd.SetUsageTemplate(inheritedFlagsUsageTemplate)
+	return nil
+}
+
+// -- HELP COMMAND -------------------------------------------------------------
+
+// DisableHelpCommandDecorator is a decorator that removes the help subcommand
+// that cobra adds to commands with subcommands. The flag --help keeps working.
+//
+// The decorator is not part of DefaultDecorators, enable it using
+// WithoutHelpCommand.
+type DisableHelpCommandDecorator struct{}
+
+// WithoutHelpCommand enables the DisableHelpCommandDecorator.
+func WithoutHelpCommand() Option {
+	return WithDecorators(DisableHelpCommandDecorator{})
+}
+
+// Decorate replaces the help command with a hidden internal command, which
+// reports itself as an unknown command. Cobra adds the help command to every
+// command with subcommands right before resolving the arguments and always
+// lists a command named "help" in the usage, so it can't be removed entirely.
+func (DisableHelpCommandDecorator) Decorate(_ *Ecdysis, cmd *cobra.Command, _ Command) error {
+	cmd.SetHelpCommand(&cobra.Command{
+		Use:    "__help",
+		Hidden: true,
+		Args: func(help *cobra.Command, _ []string) error {
+			return fmt.Errorf("unknown command %q for %q", help.Name(), cmd.CommandPath())
+		},
+		RunE: func(*cobra.Command, []string) error { return nil },
+	})
 	return nil
 }
@@ -0,0 +1,2308 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestJSONHelpDecorator(t *testing.T) {
+	e := New(WithDecorators(JSONHelpDecorator{}))
+	cmd := e.MustBuildCobraCommand(&specRootCmd{})
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"create", "--help", "--output", "json"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got Spec
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("expected valid JSON, got error %v:\n%s", err, out.String())
+	}
+
+	if got.Version != SpecVersion {
+		t.Fatalf("expected version %d, got %d", SpecVersion, got.Version)
+	}
+	if got.Command.Path != "root create" {
+		t.Fatalf("expected path %q, got %q", "root create", got.Command.Path)
+	}
+
+	var flags, inherited []string
+	for _, f := range got.Command.Flags {
+		flags = append(flags, f.Name)
+	}
+	for _, f := range got.Command.InheritedFlags {
+		inherited = append(inherited, f.Name)
+	}
+	if diff := cmp.Diff([]string{"debug", "help", "name", "output", "tags"}, flags); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff([]string{"verbose"}, inherited); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestJSONHelpDecorator_TextFallback(t *testing.T) {
+	e := New(WithDecorators(JSONHelpDecorator{}))
+	cmd := e.MustBuildCobraCommand(&specRootCmd{})
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--help"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if json.Valid(out.Bytes()) {
+		t.Fatalf("expected text help, got JSON:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Usage:") {
+		t.Fatalf("expected text help, got:\n%s", out.String())
+	}
+}
+
+type testCmdWithSliceFlag struct {
+	ports []int
+}
+
+var (
+	_ CommandWithFlags   = (*testCmdWithSliceFlag)(nil)
+	_ CommandWithExecute = (*testCmdWithSliceFlag)(nil)
+)
+
+func (c *testCmdWithSliceFlag) Usage() string { return "serve" }
+func (c *testCmdWithSliceFlag) Flags() []Flag {
+	return []Flag{{
+		Long:   "ports",
+		Usage:  "ports to listen on",
+		Ptr:    &c.ports,
+		MinLen: 1,
+		MaxLen: 3,
+		ValidateElem: func(elem any) error {
+			if port := elem.(int); port < 1 || port > 65535 {
+				return errors.New("must be between 1 and 65535")
+			}
+			return nil
+		},
+	}}
+}
+func (c *testCmdWithSliceFlag) Execute(context.Context) error { return nil }
+
+func TestCommandWithFlagsDecorator_SliceConstraints(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{{
+		name:    "too few",
+		args:    []string{},
+		wantErr: `flag "ports": expects at least 1 value(s), got 0`,
+	}, {
+		name:    "too many",
+		args:    []string{"--ports", "1,2,3,4"},
+		wantErr: `flag "ports": expects at most 3 value(s), got 4`,
+	}, {
+		name:    "out of range",
+		args:    []string{"--ports", "80,70000"},
+		wantErr: `flag "ports": invalid element 70000 at index 1: must be between 1 and 65535`,
+	}, {
+		name:    "valid",
+		args:    []string{"--ports", "80,443"},
+		wantErr: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := New().MustBuildCobraCommand(&testCmdWithSliceFlag{})
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr):
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+type testParentCmd struct {
+	subCommands []Command
+}
+
+var _ CommandWithSubCommands = (*testParentCmd)(nil)
+
+func (c *testParentCmd) Usage() string          { return "root" }
+func (c *testParentCmd) SubCommands() []Command { return c.subCommands }
+
+type testReplacementCmd struct {
+	name     string
+	args     []string
+	executed bool
+}
+
+var (
+	_ CommandWithFlags   = (*testReplacementCmd)(nil)
+	_ CommandWithArgs    = (*testReplacementCmd)(nil)
+	_ CommandWithExecute = (*testReplacementCmd)(nil)
+)
+
+func (c *testReplacementCmd) Usage() string { return "new" }
+func (c *testReplacementCmd) Flags() []Flag {
+	return []Flag{{Long: "name", Short: "n", Ptr: &c.name}}
+}
+
+func (c *testReplacementCmd) Args(args []string) error {
+	c.args = args
+	return nil
+}
+
+func (c *testReplacementCmd) Execute(context.Context) error {
+	c.executed = true
+	return nil
+}
+
+type testDeprecatedCmd struct{}
+
+var _ CommandWithDeprecatedReplacement = (*testDeprecatedCmd)(nil)
+
+func (c *testDeprecatedCmd) Usage() string      { return "old" }
+func (c *testDeprecatedCmd) Deprecated() string { return `use "new" instead` }
+func (c *testDeprecatedCmd) ReplacedBy() string { return "new" }
+
+func TestCommandWithDeprecatedDecorator_Forward(t *testing.T) {
+	replacement := &testReplacementCmd{}
+	cmd := New().MustBuildCobraCommand(&testParentCmd{
+		subCommands: []Command{replacement, &testDeprecatedCmd{}},
+	})
+	cmd.SetArgs([]string{"old", "--name", "foo", "arg1"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !replacement.executed {
+		t.Fatal("expected replacement command to be executed")
+	}
+	if replacement.name != "foo" {
+		t.Fatalf("expected flag name %q, got %q", "foo", replacement.name)
+	}
+	if diff := cmp.Diff([]string{"arg1"}, replacement.args); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestCommandWithDeprecatedDecorator_ForwardInvalidFlag(t *testing.T) {
+	replacement := &testReplacementCmd{}
+	cmd := New().MustBuildCobraCommand(&testParentCmd{
+		subCommands: []Command{replacement, &testDeprecatedCmd{}},
+	})
+	cmd.SetArgs([]string{"old", "--unknown"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	err := cmd.Execute()
+	if err == nil || err.Error() != "unknown flag: --unknown" {
+		t.Fatalf("expected unknown flag error, got %v", err)
+	}
+	if replacement.executed {
+		t.Fatal("expected replacement command not to be executed")
+	}
+}
+
+type testDeprecatedExecuteCmd struct {
+	testDeprecatedCmd
+	executed bool
+}
+
+var (
+	_ CommandWithDeprecatedReplacement = (*testDeprecatedExecuteCmd)(nil)
+	_ CommandWithExecute               = (*testDeprecatedExecuteCmd)(nil)
+	_ CommandWithConfirm               = (*testDeprecatedExecuteCmd)(nil)
+)
+
+func (c *testDeprecatedExecuteCmd) ValueToConfirm(context.Context) string { return "old" }
+func (c *testDeprecatedExecuteCmd) Execute(context.Context) error {
+	c.executed = true
+	return nil
+}
+
+func TestCommandWithDeprecatedDecorator_ForwardSkipsExecute(t *testing.T) {
+	replacement := &testReplacementCmd{}
+	deprecated := &testDeprecatedExecuteCmd{}
+	cmd := New().MustBuildCobraCommand(&testParentCmd{
+		subCommands: []Command{replacement, deprecated},
+	})
+	cmd.SetArgs([]string{"old", "--name", "foo"})
+	// the confirmation prompt of the deprecated command would fail
+	cmd.SetIn(strings.NewReader(""))
+	cmd.SetOut(io.Discard)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !replacement.executed {
+		t.Fatal("expected replacement command to be executed")
+	}
+	if deprecated.executed {
+		t.Fatal("expected deprecated command not to be executed")
+	}
+}
+
+type testDeprecatedMissingCmd struct{ testDeprecatedCmd }
+
+func (c *testDeprecatedMissingCmd) ReplacedBy() string { return "missing" }
+
+func TestCommandWithDeprecatedDecorator_ForwardMissing(t *testing.T) {
+	cmd := New().MustBuildCobraCommand(&testParentCmd{
+		subCommands: []Command{&testReplacementCmd{}, &testDeprecatedMissingCmd{}},
+	})
+	cmd.SetArgs([]string{"old"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	err := cmd.Execute()
+	if err == nil || err.Error() != `replacement command "missing" not found` {
+		t.Fatalf("expected replacement not found error, got %v", err)
+	}
+}
+
+type testCmdWithEnvFlags struct {
+	flags struct {
+		Debug bool     `long:"debug" env:"ECDYSIS_TEST_DEBUG_ALT,ECDYSIS_TEST_DEBUG"`
+		Tags  []string `long:"tags" env:"ECDYSIS_TEST_TAGS"`
+	}
+}
+
+var (
+	_ CommandWithFlags   = (*testCmdWithEnvFlags)(nil)
+	_ CommandWithExecute = (*testCmdWithEnvFlags)(nil)
+)
+
+func (c *testCmdWithEnvFlags) Usage() string                 { return "env" }
+func (c *testCmdWithEnvFlags) Flags() []Flag                 { return BuildFlags(&c.flags) }
+func (c *testCmdWithEnvFlags) Execute(context.Context) error { return nil }
+
+func TestCommandWithFlagsDecorator_EnvBool(t *testing.T) {
+	testCases := []struct {
+		env  string
+		want bool
+	}{
+		{env: "1", want: true},
+		{env: "yes", want: true},
+		{env: "ON", want: true},
+		{env: "true", want: true},
+		{env: "0", want: false},
+		{env: "no", want: false},
+		{env: "Off", want: false},
+		{env: "false", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.env, func(t *testing.T) {
+			t.Setenv("ECDYSIS_TEST_DEBUG", tc.env)
+
+			c := &testCmdWithEnvFlags{}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs([]string{})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if c.flags.Debug != tc.want {
+				t.Fatalf("expected debug %v, got %v", tc.want, c.flags.Debug)
+			}
+		})
+	}
+}
+
+func TestCommandWithFlagsDecorator_EnvBoolInvalid(t *testing.T) {
+	t.Setenv("ECDYSIS_TEST_DEBUG", "maybe")
+
+	_, err := New().BuildCobraCommand(&testCmdWithEnvFlags{})
+	if err == nil || !strings.Contains(err.Error(), `invalid boolean value "maybe"`) {
+		t.Fatalf("expected invalid boolean error, got %v", err)
+	}
+}
+
+func TestCommandWithFlagsDecorator_EnvOverriddenByFlag(t *testing.T) {
+	t.Setenv("ECDYSIS_TEST_DEBUG", "yes")
+	t.Setenv("ECDYSIS_TEST_TAGS", "a,b")
+
+	c := &testCmdWithEnvFlags{}
+	cmd := New().MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{"--debug=false", "--tags", "c"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if c.flags.Debug {
+		t.Fatal("expected flag to override environment variable")
+	}
+	if diff := cmp.Diff([]string{"c"}, c.flags.Tags); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+type testCmdWithWatch struct {
+	executions int
+	onExecute  func(executions int)
+}
+
+var _ CommandWithWatch = (*testCmdWithWatch)(nil)
+
+func (c *testCmdWithWatch) Usage() string                   { return "status" }
+func (c *testCmdWithWatch) MinWatchInterval() time.Duration { return time.Second }
+func (c *testCmdWithWatch) Execute(context.Context) error {
+	c.executions++
+	c.onExecute(c.executions)
+	return nil
+}
+
+func TestCommandWithWatchDecorator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const wantExecutions = 3
+	c := &testCmdWithWatch{
+		onExecute: func(executions int) {
+			if executions == wantExecutions {
+				cancel()
+			}
+		},
+	}
+
+	var waited []time.Duration
+	// fake clock, fires immediately until the context is cancelled
+	after := func(d time.Duration) <-chan time.Time {
+		waited = append(waited, d)
+		if ctx.Err() != nil {
+			return nil
+		}
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+
+	cmd := New(WithDecorators(CommandWithWatchDecorator{After: after})).MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{"--watch", "5s"})
+
+	done := make(chan error)
+	go func() { done <- cmd.ExecuteContext(ctx) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected watch to stop after the context was cancelled")
+	}
+
+	if c.executions != wantExecutions {
+		t.Fatalf("expected %d executions, got %d", wantExecutions, c.executions)
+	}
+	if diff := cmp.Diff([]time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second}, waited); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestCommandWithWatchDecorator_IntervalTooSmall(t *testing.T) {
+	c := &testCmdWithWatch{onExecute: func(int) {}}
+	cmd := New().MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{"--watch", "10ms"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	err := cmd.Execute()
+	if err == nil || err.Error() != "watch interval must be at least 1s" {
+		t.Fatalf("expected interval error, got %v", err)
+	}
+	if c.executions != 0 {
+		t.Fatalf("expected no executions, got %d", c.executions)
+	}
+}
+
+type testCmdWithWatchConfirm struct {
+	testCmdWithWatch
+}
+
+var (
+	_ CommandWithWatch   = (*testCmdWithWatchConfirm)(nil)
+	_ CommandWithConfirm = (*testCmdWithWatchConfirm)(nil)
+)
+
+func (c *testCmdWithWatchConfirm) MinWatchInterval() time.Duration       { return 0 }
+func (c *testCmdWithWatchConfirm) ValueToConfirm(context.Context) string { return "status" }
+
+func TestCommandWithWatchDecorator_NonPositiveInterval(t *testing.T) {
+	for _, interval := range []string{"0s", "-1s"} {
+		t.Run(interval, func(t *testing.T) {
+			c := &testCmdWithWatchConfirm{testCmdWithWatch{onExecute: func(int) {}}}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs([]string{"--watch", interval, "--force"})
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			if err == nil || err.Error() != "watch interval must be positive" {
+				t.Fatalf("expected interval error, got %v", err)
+			}
+			if c.executions != 0 {
+				t.Fatalf("expected no executions, got %d", c.executions)
+			}
+		})
+	}
+}
+
+func TestCommandWithWatchDecorator_ConfirmOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const wantExecutions = 3
+	c := &testCmdWithWatchConfirm{testCmdWithWatch{
+		onExecute: func(executions int) {
+			if executions == wantExecutions {
+				cancel()
+			}
+		},
+	}}
+	// fake clock, fires immediately until the context is cancelled
+	after := func(time.Duration) <-chan time.Time {
+		if ctx.Err() != nil {
+			return nil
+		}
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+
+	cmd := New(WithDecorators(CommandWithWatchDecorator{After: after})).MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{"--watch", "1s"})
+	// a second prompt would fail to read the input
+	cmd.SetIn(strings.NewReader("status\n"))
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+
+	if err := cmd.ExecuteContext(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.executions != wantExecutions {
+		t.Fatalf("expected %d executions, got %d", wantExecutions, c.executions)
+	}
+	if n := strings.Count(out.String(), "To proceed"); n != 1 {
+		t.Fatalf("expected 1 prompt, got %d:\n%s", n, out.String())
+	}
+}
+
+type testEntitlementChecker map[string]bool
+
+func (c testEntitlementChecker) HasEntitlement(_ context.Context, entitlement string) (bool, error) {
+	return c[entitlement], nil
+}
+
+type testCmdWithEntitlement struct {
+	executed bool
+}
+
+var (
+	_ CommandWithEntitlement = (*testCmdWithEntitlement)(nil)
+	_ CommandWithExecute     = (*testCmdWithEntitlement)(nil)
+)
+
+func (c *testCmdWithEntitlement) Usage() string       { return "audit" }
+func (c *testCmdWithEntitlement) Entitlement() string { return "enterprise" }
+func (c *testCmdWithEntitlement) Execute(context.Context) error {
+	c.executed = true
+	return nil
+}
+
+func TestCommandWithEntitlementDecorator(t *testing.T) {
+	testCases := []struct {
+		name       string
+		checker    testEntitlementChecker
+		wantErr    string
+		wantHidden bool
+	}{{
+		name:       "entitled",
+		checker:    testEntitlementChecker{"enterprise": true},
+		wantErr:    "",
+		wantHidden: false,
+	}, {
+		name:       "unentitled",
+		checker:    testEntitlementChecker{},
+		wantErr:    `permission denied: command "audit" requires entitlement "enterprise", upgrade at https://example.com`,
+		wantHidden: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &testCmdWithEntitlement{}
+			cmd := New(WithDecorators(CommandWithEntitlementDecorator{
+				Checker:        tc.checker,
+				UpgradeMessage: "upgrade at https://example.com",
+				HideUnentitled: true,
+			})).MustBuildCobraCommand(c)
+			cmd.SetArgs([]string{})
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			if cmd.Hidden != tc.wantHidden {
+				t.Fatalf("expected hidden %v, got %v", tc.wantHidden, cmd.Hidden)
+			}
+
+			err := cmd.Execute()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !c.executed {
+					t.Fatal("expected command to be executed")
+				}
+				return
+			}
+
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+			if !errors.Is(err, ErrPermission) {
+				t.Fatal("expected error to wrap ErrPermission")
+			}
+			if c.executed {
+				t.Fatal("expected command not to be executed")
+			}
+		})
+	}
+}
+
+type testCmdWithPreRunValidation struct {
+	cfg      testConfig
+	path     string
+	err      error
+	seenCfg  testConfig
+	executed bool
+}
+
+var (
+	_ CommandWithPreRunValidation = (*testCmdWithPreRunValidation)(nil)
+	_ CommandWithConfig           = (*testCmdWithPreRunValidation)(nil)
+	_ CommandWithExecute          = (*testCmdWithPreRunValidation)(nil)
+)
+
+func (c *testCmdWithPreRunValidation) Usage() string { return "deploy" }
+func (c *testCmdWithPreRunValidation) Config() Config {
+	return Config{
+		Parsed:        &c.cfg,
+		DefaultValues: testConfig{HeatLevel: 1},
+		Path:          c.path,
+	}
+}
+func (c *testCmdWithPreRunValidation) PreRunValidate(context.Context) error {
+	c.seenCfg = c.cfg
+	return c.err
+}
+func (c *testCmdWithPreRunValidation) Execute(context.Context) error {
+	c.executed = true
+	return nil
+}
+
+func TestCommandWithPreRunValidationDecorator(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "name: from-file\n")
+
+	t.Run("runs before config", func(t *testing.T) {
+		c := &testCmdWithPreRunValidation{path: path}
+		cmd := New().MustBuildCobraCommand(c)
+		cmd.SetArgs([]string{})
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if c.seenCfg != (testConfig{}) {
+			t.Fatalf("expected config not to be parsed during validation, got %+v", c.seenCfg)
+		}
+		if c.cfg.Name != "from-file" {
+			t.Fatalf("expected config to be parsed after validation, got %+v", c.cfg)
+		}
+		if !c.executed {
+			t.Fatal("expected command to be executed")
+		}
+	})
+
+	t.Run("failure skips config", func(t *testing.T) {
+		wantErr := errors.New("MY_TOKEN is not set")
+		c := &testCmdWithPreRunValidation{
+			path: writeConfigFile(t, "invalid.yaml", "name: ["),
+			err:  wantErr,
+		}
+		cmd := New().MustBuildCobraCommand(c)
+		cmd.SetArgs([]string{})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		// the config file is invalid, so the validation error is only
+		// returned if the validation runs before the config is parsed
+		if err := cmd.Execute(); !errors.Is(err, wantErr) {
+			t.Fatalf("expected error %v, got %v", wantErr, err)
+		}
+		if c.executed {
+			t.Fatal("expected command not to be executed")
+		}
+	})
+}
+
+type testCmdWithDefaultArgs struct {
+	args []string
+}
+
+var (
+	_ CommandWithDefaultArgs = (*testCmdWithDefaultArgs)(nil)
+	_ CommandWithExecute     = (*testCmdWithDefaultArgs)(nil)
+)
+
+func (c *testCmdWithDefaultArgs) Usage() string                 { return "lint [DIR]..." }
+func (c *testCmdWithDefaultArgs) DefaultArgs() []string         { return []string{"."} }
+func (c *testCmdWithDefaultArgs) Execute(context.Context) error { return nil }
+func (c *testCmdWithDefaultArgs) Args(args []string) error {
+	c.args = args
+	return nil
+}
+
+func TestCommandWithArgsDecorator_DefaultArgs(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "no args", args: []string{}, want: []string{"."}},
+		{name: "args provided", args: []string{"foo", "bar"}, want: []string{"foo", "bar"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &testCmdWithDefaultArgs{}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs(tc.args)
+
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, c.args); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+type testCmdWithDefaultArgsCount struct {
+	testCmdWithDefaultArgs
+}
+
+var _ CommandWithArgCount = (*testCmdWithDefaultArgsCount)(nil)
+
+func (c *testCmdWithDefaultArgsCount) ArgCount() (int, int) { return 1, -1 }
+func (c *testCmdWithDefaultArgsCount) ArgCountMessage() string {
+	return "expected at least one DIR"
+}
+
+func TestCommandWithArgsDecorator_DefaultArgsCount(t *testing.T) {
+	c := &testCmdWithDefaultArgsCount{}
+	cmd := New().MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"."}, c.args); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+type testCmdWithMustChange struct {
+	name string
+}
+
+var (
+	_ CommandWithFlags   = (*testCmdWithMustChange)(nil)
+	_ CommandWithExecute = (*testCmdWithMustChange)(nil)
+)
+
+func (c *testCmdWithMustChange) Usage() string { return "init" }
+func (c *testCmdWithMustChange) Flags() []Flag {
+	return []Flag{{Long: "name", Default: "CHANGEME", Ptr: &c.name, MustChange: true}}
+}
+func (c *testCmdWithMustChange) Execute(context.Context) error { return nil }
+
+func TestCommandWithFlagsDecorator_MustChange(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{{
+		name:    "unchanged",
+		args:    []string{},
+		wantErr: `flag "name": must be changed from its default value "CHANGEME"`,
+	}, {
+		name:    "set to default",
+		args:    []string{"--name", "CHANGEME"},
+		wantErr: `flag "name": must be changed from its default value "CHANGEME"`,
+	}, {
+		name:    "changed",
+		args:    []string{"--name", "my-app"},
+		wantErr: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := New().MustBuildCobraCommand(&testCmdWithMustChange{})
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr):
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+type testCmdWithDefaultFunc struct {
+	flags struct {
+		Protocol string `long:"protocol"`
+		Port     int    `long:"port"`
+	}
+}
+
+var (
+	_ CommandWithFlags   = (*testCmdWithDefaultFunc)(nil)
+	_ CommandWithExecute = (*testCmdWithDefaultFunc)(nil)
+)
+
+func (c *testCmdWithDefaultFunc) Usage() string                 { return "serve" }
+func (c *testCmdWithDefaultFunc) Execute(context.Context) error { return nil }
+func (c *testCmdWithDefaultFunc) Flags() []Flag {
+	flags := BuildFlags(&c.flags)
+	flags.SetDefault("protocol", "http")
+	flags.SetDefault("port", 80)
+	for i, f := range flags {
+		if f.Long == "port" {
+			flags[i].DefaultFunc = func(flags *pflag.FlagSet) any {
+				if protocol, _ := flags.GetString("protocol"); protocol == "https" {
+					return 443
+				}
+				return nil
+			}
+		}
+	}
+	return flags
+}
+
+func TestCommandWithFlagsDecorator_DefaultFunc(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		want int
+	}{{
+		name: "static default",
+		args: []string{},
+		want: 80,
+	}, {
+		name: "conditional default",
+		args: []string{"--protocol", "https"},
+		want: 443,
+	}, {
+		name: "explicitly set",
+		args: []string{"--protocol", "https", "--port", "8443"},
+		want: 8443,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &testCmdWithDefaultFunc{}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs(tc.args)
+
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.flags.Port != tc.want {
+				t.Fatalf("expected port %d, got %d", tc.want, c.flags.Port)
+			}
+		})
+	}
+}
+
+type testCmdWithPathFlag struct {
+	flags struct {
+		Path string `long:"path"`
+	}
+}
+
+var (
+	_ CommandWithFlags   = (*testCmdWithPathFlag)(nil)
+	_ CommandWithExecute = (*testCmdWithPathFlag)(nil)
+)
+
+func (c *testCmdWithPathFlag) Usage() string                 { return "open" }
+func (c *testCmdWithPathFlag) Execute(context.Context) error { return nil }
+func (c *testCmdWithPathFlag) Flags() []Flag {
+	flags := BuildFlags(&c.flags)
+	flags[0].PathExpand = true
+	return flags
+}
+
+func TestCommandWithFlagsDecorator_PathExpand(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		args []string
+		want string
+	}{{
+		name: "home",
+		args: []string{"--path", "~/x"},
+		want: filepath.Join(home, "x"),
+	}, {
+		name: "relative",
+		args: []string{"--path", "data/x"},
+		want: filepath.Join(wd, "data", "x"),
+	}, {
+		name: "absolute",
+		args: []string{"--path", "/var/x"},
+		want: "/var/x",
+	}, {
+		name: "empty",
+		args: []string{},
+		want: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &testCmdWithPathFlag{}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs(tc.args)
+
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.flags.Path != tc.want {
+				t.Fatalf("expected path %q, got %q", tc.want, c.flags.Path)
+			}
+		})
+	}
+}
+
+func TestCompleteFlagsDecorator(t *testing.T) {
+	e := New(WithDecorators(CompleteFlagsDecorator{}))
+	cmd := e.MustBuildCobraCommand(&testParentCmd{
+		subCommands: []Command{&specRootCmd{}},
+	})
+
+	// only the root command contains the hidden command
+	for _, sub := range cmd.Commands() {
+		for _, c := range sub.Commands() {
+			if c.Name() == completeFlagsCommandName {
+				t.Fatalf("expected %q not to contain %s", sub.Name(), completeFlagsCommandName)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{completeFlagsCommandName, "root", "create"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "--help\t-h\tbool\thelp for create\n" +
+		"--name\t-n\tstring\tresource name (required)\n" +
+		"--tags\t\tstringSlice\tresource tags\n" +
+		"--verbose\t-v\tbool\tenable verbose output\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+type testCmdWithVisibilityRoot struct {
+	testCmdWithConfig
+	subCommands []Command
+}
+
+var _ CommandWithSubCommands = (*testCmdWithVisibilityRoot)(nil)
+
+func (c *testCmdWithVisibilityRoot) SubCommands() []Command { return c.subCommands }
+
+type testCmdWithVisibility struct {
+	root *testCmdWithVisibilityRoot
+}
+
+var _ CommandWithVisibility = (*testCmdWithVisibility)(nil)
+
+func (c *testCmdWithVisibility) Usage() string { return "spicy" }
+func (c *testCmdWithVisibility) Visible() bool { return c.root.cfg.HeatLevel > 3 }
+
+func TestCommandWithVisibilityDecorator(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    bool
+	}{{
+		name:    "default config",
+		content: "",
+		want:    false,
+	}, {
+		name:    "feature enabled",
+		content: "heat-level: 5\n",
+		want:    true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := &testCmdWithVisibilityRoot{}
+			root.path = writeConfigFile(t, "config.yaml", tc.content)
+			root.subCommands = []Command{&testCmdWithVisibility{root: root}, &testCmdWithError{}}
+
+			cmd := New().MustBuildCobraCommand(root)
+			var out bytes.Buffer
+			cmd.SetOut(&out)
+			cmd.SetArgs([]string{"--help"})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.Contains(out.String(), "fail") {
+				t.Fatalf("expected help to contain the unconditional subcommand, got:\n%s", out.String())
+			}
+			if got := strings.Contains(out.String(), "spicy"); got != tc.want {
+				t.Fatalf("expected subcommand visible %v, got %v in help:\n%s", tc.want, got, out.String())
+			}
+		})
+	}
+}
+
+type testCmdWithHiddenVisibility struct {
+	testCmdWithVisibility
+}
+
+var _ CommandWithHidden = (*testCmdWithHiddenVisibility)(nil)
+
+func (c *testCmdWithHiddenVisibility) Usage() string { return "secret" }
+func (c *testCmdWithHiddenVisibility) Hidden() bool  { return true }
+
+func TestCommandWithVisibilityDecorator_KeepsHidden(t *testing.T) {
+	root := &testCmdWithVisibilityRoot{}
+	root.path = writeConfigFile(t, "config.yaml", "heat-level: 5\n")
+	root.subCommands = []Command{
+		&testCmdWithVisibility{root: root},
+		&testCmdWithHiddenVisibility{testCmdWithVisibility{root: root}},
+	}
+
+	cmd := New().MustBuildCobraCommand(root)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--help"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "spicy") {
+		t.Fatalf("expected help to contain the visible subcommand, got:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "secret") {
+		t.Fatalf("expected hidden subcommand to stay hidden, got:\n%s", out.String())
+	}
+}
+
+func TestCommandWithVisibilityDecorator_Stdin(t *testing.T) {
+	root := &testCmdWithVisibilityRoot{}
+	root.path = "-"
+	root.subCommands = []Command{&testCmdWithVisibility{root: root}}
+
+	cmd := New().MustBuildCobraCommand(root)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	// if the input was read, the subcommand would be visible
+	cmd.SetIn(strings.NewReader("heat-level: 5\n"))
+	cmd.SetArgs([]string{"--help"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out.String(), "spicy") {
+		t.Fatalf("expected standard input not to be read, got:\n%s", out.String())
+	}
+}
+
+type testCmdWithCorrelationID struct {
+	logger *slog.Logger
+	id     string
+}
+
+var (
+	_ CommandWithLogger  = (*testCmdWithCorrelationID)(nil)
+	_ CommandWithExecute = (*testCmdWithCorrelationID)(nil)
+)
+
+func (c *testCmdWithCorrelationID) Usage() string              { return "trace" }
+func (c *testCmdWithCorrelationID) Logger(logger *slog.Logger) { c.logger = logger }
+func (c *testCmdWithCorrelationID) Execute(ctx context.Context) error {
+	c.id = CorrelationIDFromContext(ctx)
+	c.logger.Info("from field")
+	LoggerFromContext(ctx).Info("from context")
+	return nil
+}
+
+func TestCorrelationIDDecorator(t *testing.T) {
+	const envVar = "ECDYSIS_TEST_CORRELATION_ID"
+	uuidRegex := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	testCases := []struct {
+		name   string
+		env    string
+		seeded string
+		want   string
+	}{
+		{name: "generated", env: ""},
+		{name: "from env", env: "trace-123", want: "trace-123"},
+		{name: "seeded", env: "trace-123", seeded: "seeded-456", want: "seeded-456"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(envVar, tc.env)
+
+			var logs bytes.Buffer
+			e := New(WithDecorators(
+				CommandWithLoggerDecorator{Logger: slog.New(slog.NewJSONHandler(&logs, nil))},
+				CorrelationIDDecorator{EnvVar: envVar},
+			))
+			c := &testCmdWithCorrelationID{}
+			cmd := e.MustBuildCobraCommand(c)
+			cmd.SetArgs([]string{})
+			ctx := SeedContext(context.Background(), ContextValues{CorrelationID: tc.seeded})
+			if err := cmd.ExecuteContext(ctx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			switch {
+			case tc.want != "" && c.id != tc.want:
+				t.Fatalf("expected correlation ID %q, got %q", tc.want, c.id)
+			case tc.want == "" && !uuidRegex.MatchString(c.id):
+				t.Fatalf("expected correlation ID to be a UUID, got %q", c.id)
+			}
+
+			dec := json.NewDecoder(&logs)
+			for _, msg := range []string{"from field", "from context"} {
+				var record map[string]any
+				if err := dec.Decode(&record); err != nil {
+					t.Fatalf("failed to parse log record %q: %v", logs.String(), err)
+				}
+				if record["msg"] != msg || record["correlation_id"] != c.id {
+					t.Fatalf("expected log record %q to contain correlation ID %q, got %v", msg, c.id, record)
+				}
+			}
+		})
+	}
+}
+
+type testCmdWithAffectedCount struct {
+	count    int
+	executed bool
+}
+
+var (
+	_ CommandWithAffectedCount = (*testCmdWithAffectedCount)(nil)
+	_ CommandWithExecute       = (*testCmdWithAffectedCount)(nil)
+)
+
+func (c *testCmdWithAffectedCount) Usage() string                              { return "delete" }
+func (c *testCmdWithAffectedCount) AffectedCount(context.Context) (int, error) { return c.count, nil }
+func (c *testCmdWithAffectedCount) AffectedCountThreshold() int                { return 3 }
+func (c *testCmdWithAffectedCount) Execute(context.Context) error {
+	c.executed = true
+	return nil
+}
+
+func TestCommandWithAffectedCountDecorator(t *testing.T) {
+	testCases := []struct {
+		name       string
+		count      int
+		args       []string
+		input      string
+		wantPrompt bool
+		options    []Option
+		wantErr    string
+	}{{
+		name:  "below threshold",
+		count: 3,
+	}, {
+		name:       "above threshold confirmed",
+		count:      5,
+		input:      "5\n",
+		wantPrompt: true,
+	}, {
+		name:       "above threshold aborted",
+		count:      5,
+		input:      "yes\n",
+		wantPrompt: true,
+		wantErr:    "action aborted",
+	}, {
+		name:       "above threshold untrimmed",
+		count:      5,
+		input:      " 5 \n",
+		wantPrompt: true,
+		wantErr:    "action aborted",
+	}, {
+		name:       "above threshold trimmed",
+		count:      5,
+		input:      " 5 \n",
+		wantPrompt: true,
+		options:    []Option{WithDecorators(CommandWithConfirmDecorator{TrimSpace: true})},
+	}, {
+		name:  "above threshold forced",
+		count: 5,
+		args:  []string{"--force"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &testCmdWithAffectedCount{count: tc.count}
+			cmd := New(tc.options...).MustBuildCobraCommand(c)
+			var out bytes.Buffer
+			cmd.SetIn(strings.NewReader(tc.input))
+			cmd.SetOut(&out)
+			cmd.SetErr(io.Discard)
+			cmd.SetArgs(tc.args)
+
+			err := cmd.Execute()
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := strings.Contains(out.String(), "This command affects 5 resources"); got != tc.wantPrompt {
+				t.Fatalf("expected prompt %v, got output %q", tc.wantPrompt, out.String())
+			}
+			if c.executed != (tc.wantErr == "") {
+				t.Fatalf("expected executed %v, got %v", tc.wantErr == "", c.executed)
+			}
+		})
+	}
+}
+
+type testCmdWithArgCount struct {
+	executed bool
+}
+
+var (
+	_ CommandWithArgCount = (*testCmdWithArgCount)(nil)
+	_ CommandWithExecute  = (*testCmdWithArgCount)(nil)
+)
+
+func (c *testCmdWithArgCount) Usage() string        { return "copy SOURCE DEST [DEST...]" }
+func (c *testCmdWithArgCount) ArgCount() (int, int) { return 2, 3 }
+func (c *testCmdWithArgCount) ArgCountMessage() string {
+	return "{{.Command}} expected <source> <dest> [dest], got {{.Got}} argument(s)"
+}
+func (c *testCmdWithArgCount) Execute(context.Context) error {
+	c.executed = true
+	return nil
+}
+
+func TestCommandWithArgCountDecorator(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{{
+		name:    "under",
+		args:    []string{"a"},
+		wantErr: "copy expected <source> <dest> [dest], got 1 argument(s)",
+	}, {
+		name: "min",
+		args: []string{"a", "b"},
+	}, {
+		name: "max",
+		args: []string{"a", "b", "c"},
+	}, {
+		name:    "over",
+		args:    []string{"a", "b", "c", "d"},
+		wantErr: "copy expected <source> <dest> [dest], got 4 argument(s)",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &testCmdWithArgCount{}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr):
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+			if c.executed != (tc.wantErr == "") {
+				t.Fatalf("expected executed %v, got %v", tc.wantErr == "", c.executed)
+			}
+		})
+	}
+}
+
+type testCmdWithArgFlagDependencies struct {
+	flags struct {
+		Value string `long:"value"`
+	}
+}
+
+var (
+	_ CommandWithArgFlagDependencies = (*testCmdWithArgFlagDependencies)(nil)
+	_ CommandWithFlags               = (*testCmdWithArgFlagDependencies)(nil)
+	_ CommandWithExecute             = (*testCmdWithArgFlagDependencies)(nil)
+)
+
+func (c *testCmdWithArgFlagDependencies) Usage() string                 { return "set [NAME --value VALUE]" }
+func (c *testCmdWithArgFlagDependencies) Flags() []Flag                 { return BuildFlags(&c.flags) }
+func (c *testCmdWithArgFlagDependencies) Execute(context.Context) error { return nil }
+func (c *testCmdWithArgFlagDependencies) ArgFlagDependencies() []ArgFlagDependency {
+	return []ArgFlagDependency{{Arg: 0, Flag: "value"}}
+}
+
+func TestCommandWithArgFlagDependenciesDecorator(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{{
+		name: "neither",
+		args: []string{},
+	}, {
+		name: "both",
+		args: []string{"name", "--value", "v"},
+	}, {
+		name:    "only argument",
+		args:    []string{"name"},
+		wantErr: `argument 1 ("name") requires flag --value`,
+	}, {
+		name:    "only flag",
+		args:    []string{"--value", "v"},
+		wantErr: "flag --value requires argument 1",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := New().MustBuildCobraCommand(&testCmdWithArgFlagDependencies{})
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr):
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+type testCmdWithConfirm struct {
+	executed bool
+}
+
+var (
+	_ CommandWithConfirm = (*testCmdWithConfirm)(nil)
+	_ CommandWithExecute = (*testCmdWithConfirm)(nil)
+)
+
+func (c *testCmdWithConfirm) Usage() string                         { return "delete" }
+func (c *testCmdWithConfirm) ValueToConfirm(context.Context) string { return "My-Pipeline" }
+func (c *testCmdWithConfirm) Execute(context.Context) error {
+	c.executed = true
+	return nil
+}
+
+func TestCommandWithConfirmDecorator(t *testing.T) {
+	testCases := []struct {
+		name      string
+		decorator CommandWithConfirmDecorator
+		input     string
+		want      bool
+	}{
+		{name: "strict exact", input: "My-Pipeline\n", want: true},
+		{name: "strict padded", input: "  My-Pipeline \n", want: false},
+		{name: "strict different case", input: "my-pipeline\n", want: false},
+		{name: "trim padded", decorator: CommandWithConfirmDecorator{TrimSpace: true}, input: "  My-Pipeline \r\n", want: true},
+		{name: "trim different case", decorator: CommandWithConfirmDecorator{TrimSpace: true}, input: "my-pipeline\n", want: false},
+		{name: "case insensitive different case", decorator: CommandWithConfirmDecorator{CaseInsensitive: true}, input: "my-pipeline\n", want: true},
+		{name: "case insensitive padded", decorator: CommandWithConfirmDecorator{CaseInsensitive: true}, input: " my-pipeline\n", want: false},
+		{name: "both", decorator: CommandWithConfirmDecorator{TrimSpace: true, CaseInsensitive: true}, input: " MY-PIPELINE \n", want: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &testCmdWithConfirm{}
+			cmd := New(WithDecorators(tc.decorator)).MustBuildCobraCommand(c)
+			cmd.SetIn(strings.NewReader(tc.input))
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+			cmd.SetArgs([]string{})
+
+			err := cmd.Execute()
+			if tc.want && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.want && (err == nil || err.Error() != "action aborted") {
+				t.Fatalf("expected action to be aborted, got %v", err)
+			}
+			if c.executed != tc.want {
+				t.Fatalf("expected executed %v, got %v", tc.want, c.executed)
+			}
+		})
+	}
+}
+
+type testCmdWithOutput struct {
+	output Output
+}
+
+var (
+	_ CommandWithOutput  = (*testCmdWithOutput)(nil)
+	_ CommandWithExecute = (*testCmdWithOutput)(nil)
+)
+
+func (c *testCmdWithOutput) Usage() string        { return "greet" }
+func (c *testCmdWithOutput) Output(output Output) { c.output = output }
+func (c *testCmdWithOutput) Execute(context.Context) error {
+	c.output.Stdout("hello\n")
+	c.output.Stderr("warning\n")
+	return nil
+}
+
+func TestCommandWithOutputDecorator(t *testing.T) {
+	cmd := New().MustBuildCobraCommand(&testCmdWithOutput{})
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdout.String() != "hello\n" {
+		t.Fatalf("expected stdout %q, got %q", "hello\n", stdout.String())
+	}
+	if stderr.String() != "warning\n" {
+		t.Fatalf("expected stderr %q, got %q", "warning\n", stderr.String())
+	}
+}
+
+func TestCommandWithOutputDecorator_WithOutput(t *testing.T) {
+	var audit bytes.Buffer
+	e := New(WithOutput(func(cmd *cobra.Command) Output {
+		return NewTeeOutput(cmd, &audit)
+	}))
+	cmd := e.MustBuildCobraCommand(&testCmdWithOutput{})
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdout.String() != "hello\n" {
+		t.Fatalf("expected stdout %q, got %q", "hello\n", stdout.String())
+	}
+	if stderr.String() != "warning\n" {
+		t.Fatalf("expected stderr %q, got %q", "warning\n", stderr.String())
+	}
+	if audit.String() != "hello\nwarning\n" {
+		t.Fatalf("expected audit log %q, got %q", "hello\nwarning\n", audit.String())
+	}
+}
+
+func TestCommandWithOutputDecorator_NewOutput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	e := New(WithOutput(func(*cobra.Command) Output {
+		return NewOutput(&stdout, &stderr)
+	}))
+	cmd := e.MustBuildCobraCommand(&testCmdWithOutput{})
+	// the writers of the cobra command are not used by the output
+	var cobraOut bytes.Buffer
+	cmd.SetOut(&cobraOut)
+	cmd.SetErr(&cobraOut)
+	cmd.SetArgs([]string{})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdout.String() != "hello\n" {
+		t.Fatalf("expected stdout %q, got %q", "hello\n", stdout.String())
+	}
+	if stderr.String() != "warning\n" {
+		t.Fatalf("expected stderr %q, got %q", "warning\n", stderr.String())
+	}
+	if cobraOut.Len() != 0 {
+		t.Fatalf("expected no output on the cobra command, got %q", cobraOut.String())
+	}
+}
+
+func TestUnknownSubCommandDecorator(t *testing.T) {
+	testCases := []struct {
+		name       string
+		args       []string
+		wantErr    string
+		wantStderr string
+	}{{
+		name:    "near miss",
+		args:    []string{"fial"},
+		wantErr: `unknown command "fial" for "root"`,
+		wantStderr: "Unknown command \"fial\" for \"root\".\n" +
+			"\n" +
+			"Did you mean this?\n" +
+			"\tfail\n" +
+			"\n" +
+			"Run 'root --help' for usage.\n",
+	}, {
+		name:    "no match",
+		args:    []string{"xyz"},
+		wantErr: `unknown command "xyz" for "root"`,
+		wantStderr: "Unknown command \"xyz\" for \"root\".\n" +
+			"\n" +
+			"Run 'root --help' for usage.\n",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := New(WithUnknownSubCommands())
+			cmd := e.MustBuildCobraCommand(&testParentCmd{subCommands: []Command{&testCmdWithError{}}})
+			var stderr bytes.Buffer
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(&stderr)
+			cmd.SilenceErrors = true
+			cmd.SetArgs(tc.args)
+
+			err := cmd.Execute()
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+			if diff := cmp.Diff(tc.wantStderr, stderr.String()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+type testCmdWithLogFile struct {
+	logger *slog.Logger
+	path   string
+	tee    bool
+	err    error
+}
+
+var (
+	_ CommandWithLogFile = (*testCmdWithLogFile)(nil)
+	_ CommandWithExecute = (*testCmdWithLogFile)(nil)
+)
+
+func (c *testCmdWithLogFile) Usage() string                    { return "sync" }
+func (c *testCmdWithLogFile) Logger(logger *slog.Logger)       { c.logger = logger }
+func (c *testCmdWithLogFile) LogFile() (path string, tee bool) { return c.path, c.tee }
+func (c *testCmdWithLogFile) Execute(context.Context) error {
+	c.logger.Info("syncing", "items", 3)
+	return c.err
+}
+
+type testCmdWithLogFileArgs struct {
+	testCmdWithLogFile
+}
+
+var _ CommandWithArgs = (*testCmdWithLogFileArgs)(nil)
+
+func (c *testCmdWithLogFileArgs) Args([]string) error { return errors.New("invalid args") }
+
+func TestCommandWithLoggerDecorator_LogFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		tee     bool
+		wantTee bool
+	}{
+		{name: "file only", tee: false, wantTee: false},
+		{name: "tee", tee: true, wantTee: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sync.log")
+			var defaultLogs bytes.Buffer
+			e := New(WithDecorators(CommandWithLoggerDecorator{
+				Logger: slog.New(slog.NewTextHandler(&defaultLogs, nil)),
+			}))
+
+			cmd := e.MustBuildCobraCommand(&testCmdWithLogFile{path: path, tee: tc.tee})
+			// run twice to make sure the logs are appended
+			for range 2 {
+				cmd.SetArgs([]string{})
+				if err := cmd.Execute(); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read log file: %v", err)
+			}
+			if n := strings.Count(string(got), "msg=syncing items=3"); n != 2 {
+				t.Fatalf("expected 2 log lines in file, got %d:\n%s", n, got)
+			}
+			if teed := strings.Contains(defaultLogs.String(), "msg=syncing"); teed != tc.wantTee {
+				t.Fatalf("expected logs passed to default logger %v, got %v", tc.wantTee, teed)
+			}
+		})
+	}
+}
+
+func TestCommandWithLoggerDecorator_LogFileClosedOnError(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cmd     func(path string) (CommandWithLogFile, *testCmdWithLogFile)
+		wantErr string
+	}{{
+		name: "execute",
+		cmd: func(path string) (CommandWithLogFile, *testCmdWithLogFile) {
+			c := &testCmdWithLogFile{path: path, err: errors.New("sync failed")}
+			return c, c
+		},
+		wantErr: "sync failed",
+	}, {
+		name: "args",
+		cmd: func(path string) (CommandWithLogFile, *testCmdWithLogFile) {
+			c := &testCmdWithLogFileArgs{testCmdWithLogFile{path: path}}
+			return c, &c.testCmdWithLogFile
+		},
+		wantErr: "invalid args",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sync.log")
+			c, lf := tc.cmd(path)
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs([]string{})
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+
+			// the file is closed, records logged afterwards are discarded
+			lf.logger.Info("after execution")
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read log file: %v", err)
+			}
+			if strings.Contains(string(got), "after execution") {
+				t.Fatalf("expected log file to be closed, got:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestCommandWithLoggerDecorator_LogFileLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.log")
+	e := New(WithDecorators(CommandWithLoggerDecorator{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelWarn})),
+	}))
+
+	c := &testCmdWithLogFile{path: path}
+	cmd := e.MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.logger.Warn("after execution")
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected empty log file, got:\n%s", got)
+	}
+}
+
+type testCmdWithDeprecatedFlag struct {
+	flags struct {
+		Name string `long:"name"`
+		Tag  string `long:"tag" deprecated:"use --name instead"`
+	}
+}
+
+var _ CommandWithFlags = (*testCmdWithDeprecatedFlag)(nil)
+
+func (c *testCmdWithDeprecatedFlag) Usage() string { return "create" }
+func (c *testCmdWithDeprecatedFlag) Flags() []Flag { return BuildFlags(&c.flags) }
+
+func TestDeprecationReportDecorator(t *testing.T) {
+	e := New(WithDecorators(DeprecationReportDecorator{}))
+	cmd := e.MustBuildCobraCommand(&testParentCmd{
+		subCommands: []Command{&testCmdWithDeprecatedFlag{}, &testReplacementCmd{}, &testDeprecatedCmd{}},
+	})
+
+	want := []DeprecatedItem{
+		{Command: "root create", Flag: "tag", Message: "use --name instead"},
+		{Command: "root old", Message: `use "new" instead`},
+	}
+	if diff := cmp.Diff(want, DeprecationReport(cmd)); diff != "" {
+		t.Fatal(diff)
+	}
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{deprecationsCommandName, "--output", "json"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []DeprecatedItem
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("failed to parse output %q: %v", out.String(), err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+
+	out.Reset()
+	cmd.SetArgs([]string{deprecationsCommandName, "--output", "text"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantText := "COMMAND       FLAG    MESSAGE\n" +
+		"root create   --tag   use --name instead\n" +
+		"root old              use \"new\" instead\n"
+	if diff := cmp.Diff(wantText, out.String()); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+type testCmdWithSecret struct {
+	flags struct {
+		Token  string `long:"token" usage:"API token" env:"ECDYSIS_TEST_TOKEN" secret:"true"`
+		Region string `long:"region" usage:"API region" env:"ECDYSIS_TEST_REGION"`
+	}
+}
+
+var (
+	_ CommandWithFlags   = (*testCmdWithSecret)(nil)
+	_ CommandWithExecute = (*testCmdWithSecret)(nil)
+)
+
+func (c *testCmdWithSecret) Usage() string                 { return "login" }
+func (c *testCmdWithSecret) Flags() []Flag                 { return BuildFlags(&c.flags) }
+func (c *testCmdWithSecret) Execute(context.Context) error { return nil }
+
+func TestCommandWithFlagsDecorator_Secret(t *testing.T) {
+	t.Setenv("ECDYSIS_TEST_TOKEN", "s3cr3t")
+	t.Setenv("ECDYSIS_TEST_REGION", "eu-west")
+
+	c := &testCmdWithSecret{}
+	cmd := New().MustBuildCobraCommand(c)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--help"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Contains(out.String(), "s3cr3t") {
+		t.Fatalf("expected help not to contain the secret, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), `API region (default "eu-west")`) {
+		t.Fatalf("expected help to contain the default of non-secret flags, got:\n%s", out.String())
+	}
+
+	// the secret is still used as the value
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.flags.Token != "s3cr3t" {
+		t.Fatalf("expected token %q, got %q", "s3cr3t", c.flags.Token)
+	}
+}
+
+type testCmdWithSecretPort struct {
+	flags struct {
+		Port int `long:"port" usage:"API port" secret:"true"`
+	}
+}
+
+var (
+	_ CommandWithFlags   = (*testCmdWithSecretPort)(nil)
+	_ CommandWithExecute = (*testCmdWithSecretPort)(nil)
+)
+
+func (c *testCmdWithSecretPort) Usage() string                 { return "login" }
+func (c *testCmdWithSecretPort) Flags() []Flag                 { return BuildFlags(&c.flags) }
+func (c *testCmdWithSecretPort) Execute(context.Context) error { return nil }
+
+func TestCommandWithFlagsDecorator_SecretNonString(t *testing.T) {
+	_, err := New().BuildCobraCommand(&testCmdWithSecretPort{})
+	wantErr := `failed to decorate command with ecdysis.CommandWithFlagsDecorator: flag "port": Secret is only supported for string flags`
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("expected error %q, got %v", wantErr, err)
+	}
+}
+
+type testPipeline struct {
+	Name   string
+	Status string
+}
+
+type testCmdWithTemplate struct{}
+
+var _ CommandWithTemplate = (*testCmdWithTemplate)(nil)
+
+func (c *testCmdWithTemplate) Usage() string           { return "describe" }
+func (c *testCmdWithTemplate) DefaultTemplate() string { return "{{.Name}} is {{.Status}}" }
+func (c *testCmdWithTemplate) TemplateData(context.Context) (any, error) {
+	return testPipeline{Name: "my-pipeline", Status: "running"}, nil
+}
+
+func TestCommandWithTemplateDecorator(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    []Option
+		args    []string
+		want    string
+		wantErr string
+	}{{
+		name: "default template",
+		args: []string{},
+		want: "my-pipeline is running\n",
+	}, {
+		name: "custom template",
+		opts: []Option{WithTemplateFlag()},
+		args: []string{"--template", "{{.Status}}: {{.Name}}\n"},
+		want: "running: my-pipeline\n",
+	}, {
+		name:    "invalid template",
+		opts:    []Option{WithTemplateFlag()},
+		args:    []string{"--template", "{{.Name"},
+		wantErr: "invalid flag --template: template: template:1: unclosed action",
+	}, {
+		name:    "flag not enabled",
+		args:    []string{"--template", "{{.Name}}"},
+		wantErr: "unknown flag: --template",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := New(tc.opts...).MustBuildCobraCommand(&testCmdWithTemplate{})
+			var out strings.Builder
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(&out)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Fatalf("expected output %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+type testCmdWithPersistentFlags struct {
+	flags struct {
+		Verbose bool `long:"verbose" usage:"enable verbose output" persistent:"true"`
+	}
+	subCommands []Command
+}
+
+var (
+	_ CommandWithFlags       = (*testCmdWithPersistentFlags)(nil)
+	_ CommandWithSubCommands = (*testCmdWithPersistentFlags)(nil)
+)
+
+func (c *testCmdWithPersistentFlags) Usage() string          { return "root" }
+func (c *testCmdWithPersistentFlags) Flags() []Flag          { return BuildFlags(&c.flags) }
+func (c *testCmdWithPersistentFlags) SubCommands() []Command { return c.subCommands }
+
+func TestInheritedFlagsHelpDecorator(t *testing.T) {
+	e := New(WithInheritedFlagsHelp())
+	cmd := e.MustBuildCobraCommand(&testCmdWithPersistentFlags{
+		subCommands: []Command{&testCmdWithDefaultFunc{}},
+	})
+
+	var out strings.Builder
+	cmd.SetArgs([]string{"serve", "--help"})
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	flags, global, ok := strings.Cut(out.String(), "Global Flags (inherited):")
+	if !ok {
+		t.Fatalf("expected section with inherited flags, got:\n%s", out.String())
+	}
+	_, flags, ok = strings.Cut(flags, "Flags:")
+	if !ok {
+		t.Fatalf("expected section with local flags, got:\n%s", out.String())
+	}
+	for _, name := range []string{"--port", "--protocol"} {
+		if !strings.Contains(flags, name) || strings.Contains(global, name) {
+			t.Fatalf("expected %s only in flags section, got:\n%s", name, out.String())
+		}
+	}
+	if !strings.Contains(global, "--verbose") || strings.Contains(flags, "--verbose") {
+		t.Fatalf("expected --verbose only in inherited section, got:\n%s", out.String())
+	}
+}
+
+func TestDisableHelpCommandDecorator(t *testing.T) {
+	newCmd := func() *cobra.Command {
+		return New(WithoutHelpCommand()).MustBuildCobraCommand(&testParentCmd{
+			subCommands: []Command{&testCmdWithDefaultFunc{}},
+		})
+	}
+
+	t.Run("help command", func(t *testing.T) {
+		cmd := newCmd()
+		cmd.SetArgs([]string{"help", "serve"})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+
+		err := cmd.Execute()
+		if err == nil || err.Error() != `unknown command "help" for "root"` {
+			t.Fatalf("expected unknown command error, got %v", err)
+		}
+		if _, _, err := cmd.Find([]string{"help"}); err == nil {
+			t.Fatal("expected help command not to be resolvable")
+		}
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		cmd := newCmd()
+		var out strings.Builder
+		cmd.SetArgs([]string{"--help"})
+		cmd.SetOut(&out)
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, commands, _ := strings.Cut(out.String(), "Available Commands:")
+		commands, _, _ = strings.Cut(commands, "Flags:")
+		if strings.Contains(commands, "help") {
+			t.Fatalf("expected help command to be absent, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("help flag", func(t *testing.T) {
+		cmd := newCmd()
+		var out strings.Builder
+		cmd.SetArgs([]string{"serve", "--help"})
+		cmd.SetOut(&out)
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(out.String(), "Usage:\n  root serve") {
+			t.Fatalf("expected help output, got:\n%s", out.String())
+		}
+	})
+}
+
+func TestRequireSubCommandDecorator(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []Option
+		args     []string
+		wantErr  string
+		wantHelp bool
+	}{{
+		name: "disabled",
+		args: []string{},
+	}, {
+		name:     "no subcommand",
+		opts:     []Option{WithRequiredSubCommands()},
+		args:     []string{},
+		wantErr:  `command "root" requires a subcommand`,
+		wantHelp: true,
+	}, {
+		name:    "unknown subcommand",
+		opts:    []Option{WithUnknownSubCommands(), WithRequiredSubCommands()},
+		args:    []string{"srve"},
+		wantErr: `unknown command "srve" for "root"`,
+	}, {
+		name: "subcommand",
+		opts: []Option{WithRequiredSubCommands()},
+		args: []string{"serve"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := New(tc.opts...).MustBuildCobraCommand(&testParentCmd{
+				subCommands: []Command{&testCmdWithDefaultFunc{}},
+			})
+			var out strings.Builder
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(&out)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr):
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+			if tc.wantHelp && !strings.HasPrefix(out.String(), "Usage:\n  root [flags]\n  root [command]") {
+				t.Fatalf("expected help output, got:\n%s", out.String())
+			}
+		})
+	}
+}
+
+type testProfileConfig struct {
+	Host string `long:"host" mapstructure:"host" usage:"server host"`
+	Port int    `long:"port" mapstructure:"port" usage:"server port"`
+}
+
+type testCmdWithProfiles struct {
+	flags             testProfileConfig
+	cfg               testProfileConfig
+	path              string
+	envPrefix         string
+	mutuallyExclusive [][]string
+}
+
+var (
+	_ CommandWithFlags   = (*testCmdWithProfiles)(nil)
+	_ CommandWithConfig  = (*testCmdWithProfiles)(nil)
+	_ CommandWithExecute = (*testCmdWithProfiles)(nil)
+)
+
+func (c *testCmdWithProfiles) Usage() string                 { return "connect" }
+func (c *testCmdWithProfiles) Execute(context.Context) error { return nil }
+func (c *testCmdWithProfiles) Flags() []Flag {
+	flags := BuildFlags(&c.flags)
+	flags.SetDefault("host", "localhost")
+	flags.SetDefault("port", 8080)
+	return flags
+}
+func (c *testCmdWithProfiles) Config() Config {
+	return Config{
+		EnvPrefix:         c.envPrefix,
+		Parsed:            &c.cfg,
+		DefaultValues:     testProfileConfig{Host: "localhost", Port: 8080},
+		Path:              c.path,
+		MutuallyExclusive: c.mutuallyExclusive,
+	}
+}
+
+func TestProfileDecorator(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "profiles:\n  staging:\n    host: staging.example.com\n    port: 9090\n")
+
+	testCases := []struct {
+		name    string
+		args    []string
+		want    testProfileConfig
+		wantErr string
+	}{{
+		name: "no profile",
+		args: []string{},
+		want: testProfileConfig{Host: "localhost", Port: 8080},
+	}, {
+		name: "profile",
+		args: []string{"--profile", "staging"},
+		want: testProfileConfig{Host: "staging.example.com", Port: 9090},
+	}, {
+		name: "flag overrides profile",
+		args: []string{"--profile", "staging", "--port", "1234"},
+		want: testProfileConfig{Host: "staging.example.com", Port: 1234},
+	}, {
+		name:    "unknown profile",
+		args:    []string{"--profile", "prod"},
+		wantErr: `invalid flag --profile: unknown profile "prod"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &testCmdWithProfiles{path: path}
+			cmd := New(WithProfiles()).MustBuildCobraCommand(c)
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, c.flags); diff != "" {
+				t.Fatalf("unexpected flags: %s", diff)
+			}
+			if diff := cmp.Diff(tc.want, c.cfg); diff != "" {
+				t.Fatalf("unexpected config: %s", diff)
+			}
+		})
+	}
+}
+
+func TestProfileDecorator_MutuallyExclusive(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "profiles:\n  staging:\n    host: staging.example.com\n")
+
+	// the profile only provides defaults, so the host doesn't count as set
+	c := &testCmdWithProfiles{path: path, mutuallyExclusive: [][]string{{"host", "port"}}}
+	cmd := New(WithProfiles()).MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{"--profile", "staging", "--port", "1234"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := testProfileConfig{Host: "staging.example.com", Port: 1234}
+	if diff := cmp.Diff(want, c.cfg); diff != "" {
+		t.Fatalf("unexpected config: %s", diff)
+	}
+	if cmd.Flags().Changed("host") {
+		t.Fatal("expected flag set by profile not to be marked as changed")
+	}
+}
+
+func TestProfileDecorator_EnvOverridesProfile(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "profiles:\n  staging:\n    host: staging.example.com\n    port: 9090\n")
+	t.Setenv("ECDYSIS_PROFILE_TEST_HOST", "env.example.com")
+
+	c := &testCmdWithProfiles{path: path, envPrefix: "ECDYSIS_PROFILE_TEST"}
+	cmd := New(WithProfiles()).MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{"--profile", "staging"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := testProfileConfig{Host: "env.example.com", Port: 9090}
+	if diff := cmp.Diff(want, c.cfg); diff != "" {
+		t.Fatalf("unexpected config: %s", diff)
+	}
+}
+
+func TestProfileDecorator_Help(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "profiles:\n  staging:\n    host: staging.example.com\n")
+
+	cmd := New(WithProfiles()).MustBuildCobraCommand(&testCmdWithProfiles{path: path})
+	var out strings.Builder
+	cmd.SetArgs([]string{"--profile", "staging", "--help"})
+	cmd.SetOut(&out)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), `server host (default "staging.example.com")`) {
+		t.Fatalf("expected profile default in help, got:\n%s", out.String())
+	}
+}
+
+type testCmdWithNextSteps struct {
+	flags struct {
+		Output string `long:"output" usage:"output format"`
+	}
+	fail bool
+}
+
+var (
+	_ CommandWithFlags     = (*testCmdWithNextSteps)(nil)
+	_ CommandWithNextSteps = (*testCmdWithNextSteps)(nil)
+)
+
+func (c *testCmdWithNextSteps) Usage() string     { return "create" }
+func (c *testCmdWithNextSteps) Flags() []Flag     { return BuildFlags(&c.flags) }
+func (c *testCmdWithNextSteps) NextSteps() string { return "Run 'cli start' to start the pipeline" }
+func (c *testCmdWithNextSteps) Execute(context.Context) error {
+	if c.fail {
+		return errors.New("creating pipeline failed")
+	}
+	return nil
+}
+
+func TestCommandWithNextStepsDecorator(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fail    bool
+		args    []string
+		want    string
+		wantErr string
+	}{{
+		name: "success",
+		args: []string{},
+		want: "Run 'cli start' to start the pipeline\n",
+	}, {
+		name: "json output",
+		args: []string{"--output", "json"},
+		want: "",
+	}, {
+		name:    "error",
+		fail:    true,
+		args:    []string{},
+		want:    "",
+		wantErr: "creating pipeline failed",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := New().MustBuildCobraCommand(&testCmdWithNextSteps{fail: tc.fail})
+			var stderr strings.Builder
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(&stderr)
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+
+			err := cmd.Execute()
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := stderr.String(); got != tc.want {
+				t.Fatalf("expected output %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+type testCmdWithBatch struct {
+	processed []string
+}
+
+var _ CommandWithBatch = (*testCmdWithBatch)(nil)
+
+func (c *testCmdWithBatch) Usage() string { return "delete" }
+func (c *testCmdWithBatch) BatchItems(context.Context) ([]string, error) {
+	return []string{"a", "b", "c"}, nil
+}
+func (c *testCmdWithBatch) ProcessItem(_ context.Context, item string) error {
+	c.processed = append(c.processed, item)
+	if item == "b" {
+		return fmt.Errorf("pipeline %q: %w", item, ErrNotFound)
+	}
+	return nil
+}
+
+func TestCommandWithBatchDecorator(t *testing.T) {
+	testCases := []struct {
+		name          string
+		args          []string
+		wantProcessed []string
+		wantSummary   string
+		wantErr       string
+	}{{
+		name:          "stop at first error",
+		args:          []string{},
+		wantProcessed: []string{"a", "b"},
+		wantSummary:   "Processed 2 of 3 items: 1 succeeded, 1 failed\n",
+		wantErr:       `item "b": pipeline "b": not found`,
+	}, {
+		name:          "continue on error",
+		args:          []string{"--continue-on-error"},
+		wantProcessed: []string{"a", "b", "c"},
+		wantSummary:   "Processed 3 of 3 items: 2 succeeded, 1 failed\n",
+		wantErr:       `1 of 3 items failed: item "b": pipeline "b": not found`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &testCmdWithBatch{}
+			e := New(WithErrorClassifier(DefaultErrorClassifier()))
+			cmd := e.MustBuildCobraCommand(c)
+			var stderr strings.Builder
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(&stderr)
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+
+			err := cmd.Execute()
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+			if got := e.exitCode(err); got != ExitCodeNotFound {
+				t.Fatalf("expected exit code %d, got %d", ExitCodeNotFound, got)
+			}
+			if diff := cmp.Diff(tc.wantProcessed, c.processed); diff != "" {
+				t.Fatal(diff)
+			}
+			if got := stderr.String(); got != tc.wantSummary {
+				t.Fatalf("expected summary %q, got %q", tc.wantSummary, got)
+			}
+		})
+	}
+}
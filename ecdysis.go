@@ -15,8 +15,14 @@
 package ecdysis
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
 	"reflect"
+	"slices"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -26,6 +32,41 @@ import (
 type Ecdysis struct {
 	// Decorators is a list of decorators that are applied to all commands.
 	Decorators []Decorator
+	// ErrorClassifier maps errors returned by commands to exit codes in Run.
+	// If nil, all errors result in ExitCodeError.
+	ErrorClassifier *ErrorClassifier
+
+	// The following fields contain state collected while decorating a single
+	// command. BuildCobraCommand decorates every command using its own copy of
+	// the instance (see forCommand), so the state is released together with
+	// the command and is not shared between commands.
+
+	// helpConfigParsers contains functions parsing the configuration of the
+	// command and its ancestors, used to resolve the configuration before the
+	// help is rendered.
+	helpConfigParsers []func() error
+	// visibleSubCommands contains the subcommands implementing
+	// CommandWithVisibility.
+	visibleSubCommands []visibleSubCommand
+	// profileDefaults returns the values of the profile selected using
+	// ProfileDecorator, which are used as configuration defaults.
+	profileDefaults func() map[string]any
+	// logger is the logger provided to the command by
+	// CommandWithLoggerDecorator.
+	logger *slog.Logger
+	// finishers are applied to the command after all decorators, they can
+	// wrap the hooks installed by decorators running later. They are applied
+	// in reverse order, so finishers of earlier decorators wrap the hooks set
+	// by finishers of later decorators.
+	finishers []func(cmd *cobra.Command)
+	// configReloader parses the configuration of the command into a new
+	// value, used by ConfigReloadDecorator.
+	configReloader func(cmd *cobra.Command, parsed any) error
+	// newOutput creates the Output used by decorators, set using WithOutput.
+	newOutput func(*cobra.Command) Output
+	// deadline bounds the total runtime of commands executed using Run, set
+	// using WithDeadline.
+	deadline time.Duration
 }
 
 // Command is an interface that represents a command that can be decorated and
@@ -75,6 +116,7 @@ func New(opts ...Option) *Ecdysis {
 // BuildCobraCommand creates a new cobra.Command instance from the provided
 // Command instance. It decorates the command with all registered decorators.
 func (e *Ecdysis) BuildCobraCommand(c Command) (*cobra.Command, error) {
+	e = e.forCommand()
 	cmd := &cobra.Command{
 		Use: c.Usage(),
 	}
@@ -84,6 +126,9 @@ func (e *Ecdysis) BuildCobraCommand(c Command) (*cobra.Command, error) {
 			return nil, fmt.Errorf("failed to decorate command with %T: %w", d, err)
 		}
 	}
+	for _, finish := range slices.Backward(e.finishers) {
+		finish(cmd)
+	}
 
 	return cmd, nil
 }
@@ -99,6 +144,104 @@ func (e *Ecdysis) MustBuildCobraCommand(c Command) *cobra.Command {
 	return cmd
 }
 
+// ApplyDecorator creates a new cobra.Command instance from the provided Command
+// instance and decorates it only with the provided decorator. It is meant for
+// testing the behavior of a single decorator in isolation.
+func ApplyDecorator(d Decorator, c Command) (*cobra.Command, error) {
+	return New(WithoutDefaultDecorators(), WithDecorators(d)).BuildCobraCommand(c)
+}
+
+// Run builds the cobra command from the provided Command, executes it using
+// the arguments in os.Args and returns the exit code. It is meant to be used in
+// the main function:
+//
+//	os.Exit(ecdysis.New().Run(&RootCommand{}))
+func (e *Ecdysis) Run(c Command) int {
+	return e.run(context.Background(), c, nil)
+}
+
+// run executes the command with the provided args, if args is nil os.Args is
+// used.
+func (e *Ecdysis) run(ctx context.Context, c Command, args []string) int {
+	cmd, err := e.BuildCobraCommand(c)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return ExitCodeError
+	}
+	return e.execute(ctx, cmd, args)
+}
+
+// execute executes the cobra command with the provided args, if args is nil
+// os.Args is used.
+func (e *Ecdysis) execute(ctx context.Context, cmd *cobra.Command, args []string) int {
+	if args != nil {
+		cmd.SetArgs(args)
+	}
+
+	if e.deadline <= 0 {
+		err := cmd.ExecuteContext(ctx)
+		return e.exitCode(err)
+	}
+
+	errDeadline := fmt.Errorf("command did not finish within the deadline of %s", e.deadline)
+	ctx, cancel := context.WithTimeoutCause(ctx, e.deadline, errDeadline)
+	defer cancel()
+
+	// errors are printed after they are wrapped, so that the user sees that
+	// the deadline was exceeded
+	silenceErrors := cmd.SilenceErrors
+	cmd.SilenceErrors = true
+	c, err := cmd.ExecuteContextC(ctx)
+	cmd.SilenceErrors = silenceErrors
+
+	if err != nil && errors.Is(context.Cause(ctx), errDeadline) {
+		err = fmt.Errorf("%w: %w", errDeadline, err)
+	}
+	if c == nil {
+		c = cmd
+	}
+	if err != nil && !silenceErrors && !c.SilenceErrors {
+		c.PrintErrln(c.ErrPrefix(), err.Error())
+	}
+	return e.exitCode(err)
+}
+
+// exitCode returns the exit code for the error returned by a command.
+func (e *Ecdysis) exitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitCodeOK
+	case e.ErrorClassifier != nil:
+		return e.ErrorClassifier.ExitCode(err)
+	default:
+		return ExitCodeError
+	}
+}
+
+// forCommand returns a copy of the instance used to decorate a single command.
+// The help config parsers of the ancestors are inherited, other state collected
+// while decorating the parent is reset.
+func (e *Ecdysis) forCommand() *Ecdysis {
+	cp := *e
+	// clipping makes sure appending doesn't modify the slice of the parent
+	cp.helpConfigParsers = slices.Clip(e.helpConfigParsers)
+	cp.visibleSubCommands = nil
+	cp.profileDefaults = nil
+	cp.logger = nil
+	cp.configReloader = nil
+	cp.finishers = nil
+	return &cp
+}
+
+// output returns the Output for the command, created by the function supplied
+// in WithOutput or NewDefaultOutput.
+func (e *Ecdysis) output(cmd *cobra.Command) Output {
+	if e.newOutput != nil {
+		return e.newOutput(cmd)
+	}
+	return NewDefaultOutput(cmd)
+}
+
 // Option is a function type that modifies an Ecdysis instance.
 type Option func(*Ecdysis)
 
@@ -113,6 +256,29 @@ func getDecoratorType(d Decorator) reflect.Type {
 	return t
 }
 
+// WithOutput sets the function creating the Output provided to commands
+// implementing CommandWithOutput and used by decorators to write messages to
+// the user. By default, NewDefaultOutput is used.
+//
+//	ecdysis.New(ecdysis.WithOutput(func(cmd *cobra.Command) ecdysis.Output {
+//		return ecdysis.NewTeeOutput(cmd, auditLog)
+//	}))
+func WithOutput(newOutput func(cmd *cobra.Command) Output) Option {
+	return func(e *Ecdysis) {
+		e.newOutput = newOutput
+	}
+}
+
+// WithDeadline bounds the total runtime of commands executed using Run. When
+// the deadline is exceeded, the context passed to the command is cancelled and
+// Run reports an error stating that the deadline was exceeded. Commands need to
+// respect the cancellation of the context to be stopped.
+func WithDeadline(d time.Duration) Option {
+	return func(e *Ecdysis) {
+		e.deadline = d
+	}
+}
+
 // WithoutDefaultDecorators removes all default decorators.
 func WithoutDefaultDecorators() Option {
 	return func(e *Ecdysis) {
@@ -18,8 +18,11 @@ package ecdysis
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
@@ -222,3 +225,90 @@ func TestBuildCommandWithFlags(t *testing.T) {
 		t.Fatal(v)
 	}
 }
+
+func TestApplyDecorator(t *testing.T) {
+	t.Run("docs", func(t *testing.T) {
+		cmd, err := ApplyDecorator(CommandWithDocsDecorator{}, &testCmd{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cmd.Short != "short-foo" || cmd.Long != "long-bar" || cmd.Example != "example-baz" {
+			t.Fatalf("expected docs to be set, got %q, %q, %q", cmd.Short, cmd.Long, cmd.Example)
+		}
+		// other decorators are not applied
+		if cmd.Flags().Lookup("long-foo") != nil {
+			t.Fatal("expected flags not to be set")
+		}
+		if len(cmd.Aliases) != 0 || cmd.HasSubCommands() {
+			t.Fatal("expected aliases and subcommands not to be set")
+		}
+	})
+
+	t.Run("flags", func(t *testing.T) {
+		c := &testCmd{}
+		cmd, err := ApplyDecorator(CommandWithFlagsDecorator{}, c)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cmd.Short != "" {
+			t.Fatalf("expected docs not to be set, got %q", cmd.Short)
+		}
+
+		if err := cmd.ParseFlags([]string{"-l", "val"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if c.flagLongFoo != "val" {
+			t.Fatalf("expected flag value %q, got %q", "val", c.flagLongFoo)
+		}
+	})
+}
+
+type testCmdWithSleep struct{}
+
+var _ CommandWithExecute = (*testCmdWithSleep)(nil)
+
+func (c *testCmdWithSleep) Usage() string { return "sleep" }
+func (c *testCmdWithSleep) Execute(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Minute):
+		return nil
+	}
+}
+
+func TestEcdysis_Run_Deadline(t *testing.T) {
+	e := New(WithDeadline(10 * time.Millisecond))
+	cmd := e.MustBuildCobraCommand(&testCmdWithSleep{})
+	var stderr strings.Builder
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(&stderr)
+
+	start := time.Now()
+	if got := e.execute(context.Background(), cmd, []string{}); got != ExitCodeError {
+		t.Fatalf("expected exit code %d, got %d", ExitCodeError, got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected command to be cancelled at the deadline, took %s", elapsed)
+	}
+
+	want := "Error: command did not finish within the deadline of 10ms: context deadline exceeded\n"
+	if got := stderr.String(); !strings.HasPrefix(got, want) {
+		t.Fatalf("expected error output to start with %q, got %q", want, got)
+	}
+}
+
+func TestEcdysis_Run_DeadlineNotExceeded(t *testing.T) {
+	e := New(WithDeadline(time.Minute))
+	cmd := e.MustBuildCobraCommand(&testCmdWithError{err: errors.New("boom")})
+	var stderr strings.Builder
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(&stderr)
+
+	if got := e.execute(context.Background(), cmd, []string{}); got != ExitCodeError {
+		t.Fatalf("expected exit code %d, got %d", ExitCodeError, got)
+	}
+	if got, want := stderr.String(), "Error: boom\n"; !strings.HasPrefix(got, want) {
+		t.Fatalf("expected error output to start with %q, got %q", want, got)
+	}
+}
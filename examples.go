@@ -0,0 +1,135 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// runnableExamplesAnnotation is the annotation of commands containing the
+// runnable examples (see Docs.RunnableExamples), separated by newlines.
+const runnableExamplesAnnotation = "ecdysis_runnable_examples"
+
+// RunnableExamples walks the command tree starting at root and returns the
+// runnable examples of all commands (see Docs.RunnableExamples).
+func RunnableExamples(root *cobra.Command) []string {
+	var examples []string
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if v, ok := cmd.Annotations[runnableExamplesAnnotation]; ok {
+			examples = append(examples, strings.Split(v, "\n")...)
+		}
+		for _, sub := range cmd.Commands() {
+			walk(sub)
+		}
+	}
+	walk(root)
+	return examples
+}
+
+// RunExamples builds the command tree from c and executes all runnable
+// examples in it (see Docs.RunnableExamples), discarding their output. It
+// returns an error for every example that fails, so that stale examples can be
+// caught in tests:
+//
+//	func TestExamples(t *testing.T) {
+//		if err := ecdysis.RunExamples(ecdysis.New(), &RootCommand{}); err != nil {
+//			t.Fatal(err)
+//		}
+//	}
+//
+// The command tree is built anew for every example, so that flags set by one
+// example don't leak into the next one. Arguments are separated by whitespace,
+// single or double quotes can be used to pass arguments containing whitespace.
+func RunExamples(e *Ecdysis, c Command) error {
+	root, err := e.BuildCobraCommand(c)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, ex := range RunnableExamples(root) {
+		if err := runExample(e, c, ex); err != nil {
+			errs = append(errs, fmt.Errorf("example %q: %w", ex, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func runExample(e *Ecdysis, c Command, example string) error {
+	args, err := splitExample(example)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("empty example")
+	}
+
+	root, err := e.BuildCobraCommand(c)
+	if err != nil {
+		return err
+	}
+	if args[0] != root.Name() {
+		return fmt.Errorf("example needs to start with %q", root.Name())
+	}
+
+	root.SetArgs(args[1:])
+	root.SetOut(io.Discard)
+	root.SetErr(io.Discard)
+	return root.ExecuteContext(context.Background()) //nolint:wrapcheck // wrapped by the caller
+}
+
+// splitExample splits the example into arguments. Arguments are separated by
+// whitespace, quotes group arguments containing whitespace.
+func splitExample(example string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+	)
+	for _, r := range example {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %q", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,163 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testCmdWithExamplesRoot struct {
+	examples []string
+}
+
+var (
+	_ CommandWithDocs        = (*testCmdWithExamplesRoot)(nil)
+	_ CommandWithSubCommands = (*testCmdWithExamplesRoot)(nil)
+)
+
+func (c *testCmdWithExamplesRoot) Usage() string { return "mycli" }
+func (c *testCmdWithExamplesRoot) Docs() Docs {
+	return Docs{RunnableExamples: c.examples}
+}
+func (c *testCmdWithExamplesRoot) SubCommands() []Command {
+	return []Command{&testCmdWithExamples{}}
+}
+
+type testCmdWithExamples struct {
+	flags struct {
+		Name string `long:"name" usage:"pipeline name"`
+	}
+}
+
+var (
+	_ CommandWithDocs    = (*testCmdWithExamples)(nil)
+	_ CommandWithFlags   = (*testCmdWithExamples)(nil)
+	_ CommandWithExecute = (*testCmdWithExamples)(nil)
+)
+
+func (c *testCmdWithExamples) Usage() string { return "create" }
+func (c *testCmdWithExamples) Flags() []Flag { return BuildFlags(&c.flags) }
+func (c *testCmdWithExamples) Docs() Docs {
+	return Docs{
+		Example: "  # create a pipeline",
+		RunnableExamples: []string{
+			"mycli create --name my-pipeline",
+			"mycli create --name 'my pipeline'",
+		},
+	}
+}
+
+func (c *testCmdWithExamples) Execute(context.Context) error {
+	if c.flags.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func TestRunExamples(t *testing.T) {
+	err := RunExamples(New(), &testCmdWithExamplesRoot{
+		examples: []string{"mycli create --name foo"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRunExamples_Stale(t *testing.T) {
+	err := RunExamples(New(), &testCmdWithExamplesRoot{
+		examples: []string{
+			"mycli create --name foo",
+			"mycli create --id foo",
+			"mycli create",
+		},
+	})
+
+	want := `example "mycli create --id foo": unknown flag: --id` + "\n" +
+		`example "mycli create": name is required`
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, got %v", want, err)
+	}
+}
+
+func TestRunnableExamples(t *testing.T) {
+	root := New().MustBuildCobraCommand(&testCmdWithExamplesRoot{
+		examples: []string{"mycli create --name foo"},
+	})
+
+	want := []string{
+		"mycli create --name foo",
+		"mycli create --name my-pipeline",
+		"mycli create --name 'my pipeline'",
+	}
+	if diff := cmp.Diff(want, RunnableExamples(root)); diff != "" {
+		t.Fatal(diff)
+	}
+
+	create, _, err := root.Find([]string{"create"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantExample := "  # create a pipeline\n  mycli create --name my-pipeline\n  mycli create --name 'my pipeline'"
+	if create.Example != wantExample {
+		t.Fatalf("expected example %q, got %q", wantExample, create.Example)
+	}
+}
+
+func TestSplitExample(t *testing.T) {
+	testCases := []struct {
+		name    string
+		example string
+		want    []string
+		wantErr string
+	}{{
+		name:    "plain",
+		example: "mycli create  --name foo",
+		want:    []string{"mycli", "create", "--name", "foo"},
+	}, {
+		name:    "quoted",
+		example: `mycli describe --template '{{.Name}} {{.Status}}' --name "my pipeline"`,
+		want:    []string{"mycli", "describe", "--template", "{{.Name}} {{.Status}}", "--name", "my pipeline"},
+	}, {
+		name:    "empty quotes",
+		example: `mycli create --name ""`,
+		want:    []string{"mycli", "create", "--name", ""},
+	}, {
+		name:    "unterminated quote",
+		example: `mycli create --name "foo`,
+		wantErr: `unterminated quote '"'`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := splitExample(tc.example)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+)
+
+// Exit codes returned by Ecdysis.Run. Codes of categorized errors follow the
+// conventions of sysexits.h.
+const (
+	ExitCodeOK         = 0
+	ExitCodeError      = 1
+	ExitCodeValidation = 65 // EX_DATAERR
+	ExitCodeNotFound   = 66 // EX_NOINPUT
+	ExitCodeNetwork    = 69 // EX_UNAVAILABLE
+	ExitCodeSoftware   = 70 // EX_SOFTWARE
+	ExitCodePermission = 77 // EX_NOPERM
+)
+
+// Sentinel errors used to categorize errors returned by commands. Commands
+// should wrap them (e.g. fmt.Errorf("pipeline %q: %w", id, ErrNotFound)) so
+// that the ErrorClassifier can pick the matching exit code.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrPermission = errors.New("permission denied")
+	ErrValidation = errors.New("validation failed")
+	ErrNetwork    = errors.New("network error")
+)
+
+// ErrorCategory is a category of errors mapped to an exit code.
+type ErrorCategory struct {
+	// Name is a human readable name of the category (e.g. "not-found").
+	Name string
+	// ExitCode is the exit code returned for errors in this category.
+	ExitCode int
+	// Match reports whether the error belongs to this category.
+	Match func(error) bool
+}
+
+// ErrorClassifier maps errors returned by commands to exit codes.
+type ErrorClassifier struct {
+	// Categories are checked in order, the first matching category determines
+	// the exit code.
+	Categories []ErrorCategory
+	// DefaultExitCode is returned for errors that don't match any category.
+	// If zero, ExitCodeError is used.
+	DefaultExitCode int
+}
+
+// DefaultErrorClassifier returns a classifier that maps the sentinel errors
+// ErrNotFound, ErrPermission, ErrValidation and ErrNetwork, as well as the
+// related errors from the standard library (fs.ErrNotExist, fs.ErrPermission
+// and net.Error), to their exit codes.
+func DefaultErrorClassifier() ErrorClassifier {
+	return ErrorClassifier{
+		Categories: []ErrorCategory{{
+			Name:     "not-found",
+			ExitCode: ExitCodeNotFound,
+			Match:    errorIsAny(ErrNotFound, fs.ErrNotExist),
+		}, {
+			Name:     "permission",
+			ExitCode: ExitCodePermission,
+			Match:    errorIsAny(ErrPermission, fs.ErrPermission),
+		}, {
+			Name:     "validation",
+			ExitCode: ExitCodeValidation,
+			Match:    errorIsAny(ErrValidation),
+		}, {
+			Name:     "network",
+			ExitCode: ExitCodeNetwork,
+			Match: func(err error) bool {
+				var netErr net.Error
+				return errors.Is(err, ErrNetwork) || errors.As(err, &netErr)
+			},
+		}},
+	}
+}
+
+// ExitCode returns the exit code for the error. A nil error results in
+// ExitCodeOK.
+func (c ErrorClassifier) ExitCode(err error) int {
+	if err == nil {
+		return ExitCodeOK
+	}
+	for _, cat := range c.Categories {
+		if cat.Match != nil && cat.Match(err) {
+			return cat.ExitCode
+		}
+	}
+	if c.DefaultExitCode != 0 {
+		return c.DefaultExitCode
+	}
+	return ExitCodeError
+}
+
+// WithErrorClassifier enables the classification of errors returned by
+// commands executed with Ecdysis.Run.
+func WithErrorClassifier(c ErrorClassifier) Option {
+	return func(e *Ecdysis) {
+		e.ErrorClassifier = &c
+	}
+}
+
+func errorIsAny(targets ...error) func(error) bool {
+	return func(err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestErrorClassifier_ExitCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: ExitCodeOK},
+		{name: "uncategorized", err: errors.New("boom"), want: ExitCodeError},
+		{name: "not found", err: fmt.Errorf("pipeline %q: %w", "foo", ErrNotFound), want: ExitCodeNotFound},
+		{name: "file not found", err: &os.PathError{Op: "open", Path: "foo", Err: os.ErrNotExist}, want: ExitCodeNotFound},
+		{name: "permission", err: fmt.Errorf("delete: %w", ErrPermission), want: ExitCodePermission},
+		{name: "file permission", err: &os.PathError{Op: "open", Path: "foo", Err: os.ErrPermission}, want: ExitCodePermission},
+		{name: "validation", err: fmt.Errorf("invalid name: %w", ErrValidation), want: ExitCodeValidation},
+		{name: "network", err: fmt.Errorf("request failed: %w", ErrNetwork), want: ExitCodeNetwork},
+		{name: "net error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: ExitCodeNetwork},
+	}
+
+	classifier := DefaultErrorClassifier()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classifier.ExitCode(tc.err); got != tc.want {
+				t.Fatalf("expected exit code %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+type testCmdWithError struct {
+	err error
+}
+
+var _ CommandWithExecute = (*testCmdWithError)(nil)
+
+func (c *testCmdWithError) Usage() string                 { return "fail" }
+func (c *testCmdWithError) Execute(context.Context) error { return c.err }
+
+func TestEcdysis_Run_ExitCode(t *testing.T) {
+	cmd := &testCmdWithError{err: fmt.Errorf("pipeline %q: %w", "foo", ErrNotFound)}
+
+	if got := New().run(context.Background(), cmd, []string{}); got != ExitCodeError {
+		t.Fatalf("expected exit code %d without classifier, got %d", ExitCodeError, got)
+	}
+
+	e := New(WithErrorClassifier(DefaultErrorClassifier()))
+	if got := e.run(context.Background(), cmd, []string{}); got != ExitCodeNotFound {
+		t.Fatalf("expected exit code %d with classifier, got %d", ExitCodeNotFound, got)
+	}
+
+	cmd.err = nil
+	if got := e.run(context.Background(), cmd, []string{}); got != ExitCodeOK {
+		t.Fatalf("expected exit code %d on success, got %d", ExitCodeOK, got)
+	}
+}
@@ -16,8 +16,16 @@ package ecdysis
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // Flag describes a single command line flag.
@@ -35,14 +43,183 @@ type Flag struct {
 	// Default is the default value when the flag is not explicitly supplied.
 	// It should have the same type as the value behind the pointer in field Ptr.
 	Default any
-	// Ptr is a pointer to the value into which the flag will be parsed.
+	// DefaultFunc computes the default value based on other flags (e.g. a
+	// default port depending on --protocol). It is called after the flags are
+	// parsed if the flag was neither supplied nor seeded from an environment
+	// variable. The returned value should have the same type as Default, nil
+	// keeps the value of Default.
+	DefaultFunc func(flags *pflag.FlagSet) any
+	// Ptr is a pointer to the value into which the flag will be parsed. Besides
+	// pointers to the supported primitive types and slices, it can be a custom
+	// pflag.Value (e.g. *Enum).
 	Ptr any
 	// Hidden is used to mark the flag as hidden.
 	Hidden bool
+	// EnvVars is a list of environment variables from which the default value
+	// of the flag is seeded. The first environment variable that is set is
+	// used. Boolean flags accept common truthy and falsy values (e.g. "yes",
+	// "on", "1", "no", "off", "0").
+	EnvVars []string
+	// MustChange is used to require the flag value to differ from its default
+	// value. This is useful for flags with a placeholder default value (e.g.
+	// "CHANGEME") and stricter than Required, which only checks that the flag
+	// was supplied.
+	MustChange bool
+	// PathExpand is used to normalize the value of a string flag containing a
+	// file path. After the flags are parsed, a leading "~" is expanded to the
+	// home directory of the user and the path is made absolute.
+	PathExpand bool
+
+	// MinLen is the minimum number of values a slice flag needs to contain.
+	// Zero means no lower bound. Only supported for slice flags.
+	MinLen int
+	// MaxLen is the maximum number of values a slice flag can contain. Zero
+	// means no upper bound. Only supported for slice flags.
+	MaxLen int
+	// ValidateElem is called for each element of a slice flag and should
+	// return an error if the element is not valid. Only supported for slice
+	// flags.
+	ValidateElem func(elem any) error
+
+	// Secret is used to hide the default value of the flag in the help (e.g.
+	// for tokens seeded from environment variables). It is only supported for
+	// string flags.
+	Secret bool
+	// Deprecated is a message shown when the flag is used. Deprecated flags are
+	// hidden from the help.
+	Deprecated string
+
+	// ConfigKey is the key of the configuration value the flag is bound to
+	// (see CommandWithConfig). If empty, the flag is bound to the key Long.
+	ConfigKey string
+
+	// Aliases are additional long names of the flag (e.g. the old name of a
+	// renamed flag). Aliases are hidden from the help and set the value of the
+	// flag, if the flag is supplied multiple times the last value wins.
+	Aliases []string
+}
+
+// configKeyAnnotation is the pflag annotation storing Flag.ConfigKey.
+const configKeyAnnotation = "ecdysis_config_key"
+
+// BuildFlagsOption is a function type that configures BuildFlags.
+type BuildFlagsOption func(*buildFlagsOptions)
+
+type buildFlagsOptions struct {
+	mapstructureKeys bool
+}
+
+// WithMapstructureConfigKeys makes BuildFlags use the mapstructure tag of a
+// field as the ConfigKey of the flag, so the flag is bound to the same
+// configuration key that is used when unmarshalling the configuration. Tags of
+// nested structs are joined with dots (e.g. "database.host"). Fields without a
+// mapstructure tag are bound to their long name.
+func WithMapstructureConfigKeys() BuildFlagsOption {
+	return func(o *buildFlagsOptions) {
+		o.mapstructureKeys = true
+	}
 }
 
 type Flags []Flag
 
+// Enum is a flag value that accepts only one of the allowed values. It
+// implements pflag.Value and can be used as the type of a field passed to
+// BuildFlags or as Flag.Ptr.
+type Enum struct {
+	// Allowed contains the values accepted by the flag.
+	Allowed []string
+	// Value is the current value of the flag.
+	Value string
+}
+
+// NewEnum returns an Enum with the default value and the allowed values.
+func NewEnum(value string, allowed ...string) Enum {
+	return Enum{Allowed: allowed, Value: value}
+}
+
+// String returns the current value.
+func (e *Enum) String() string { return e.Value }
+
+// Type returns the type name shown in the help.
+func (e *Enum) Type() string { return "string" }
+
+// Set sets the value if it is one of the allowed values, otherwise it returns
+// an error listing the allowed values.
+func (e *Enum) Set(s string) error {
+	if !slices.Contains(e.Allowed, s) {
+		return EnumError(e.Allowed)
+	}
+	e.Value = s
+	return nil
+}
+
+// EnumError returns the error reported when a flag value is not one of the
+// allowed values. Custom pflag.Value implementations can use it to report
+// errors consistently with Enum.
+func EnumError(allowed []string) error {
+	return fmt.Errorf("must be one of [%s]", strings.Join(allowed, ", "))
+}
+
+// hasSliceConstraints returns true if any of the slice constraints is set.
+func (f Flag) hasSliceConstraints() bool {
+	return f.MinLen > 0 || f.MaxLen > 0 || f.ValidateElem != nil
+}
+
+// validateSlice checks the current value of a slice flag against the
+// constraints MinLen, MaxLen and ValidateElem.
+func (f Flag) validateSlice() error {
+	v := reflect.ValueOf(f.Ptr).Elem()
+
+	if f.MinLen > 0 && v.Len() < f.MinLen {
+		return fmt.Errorf("flag %q: expects at least %d value(s), got %d", f.Long, f.MinLen, v.Len())
+	}
+	if f.MaxLen > 0 && v.Len() > f.MaxLen {
+		return fmt.Errorf("flag %q: expects at most %d value(s), got %d", f.Long, f.MaxLen, v.Len())
+	}
+	if f.ValidateElem != nil {
+		for i := 0; i < v.Len(); i++ {
+			elem := v.Index(i).Interface()
+			if err := f.ValidateElem(elem); err != nil {
+				return fmt.Errorf("flag %q: invalid element %v at index %d: %w", f.Long, elem, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyDefaultFunc sets the flag to the value returned by DefaultFunc, unless
+// the flag was supplied or seeded from the environment.
+func (f Flag) applyDefaultFunc(flags *pflag.FlagSet) error {
+	pf := flags.Lookup(f.Long)
+	if pf == nil || pf.Changed {
+		return nil
+	}
+	if _, _, ok := f.lookupEnv(); ok {
+		return nil
+	}
+
+	val := f.DefaultFunc(flags)
+	if val == nil {
+		return nil
+	}
+
+	var err error
+	rv := reflect.ValueOf(val)
+	if sv, ok := pf.Value.(pflag.SliceValue); ok && rv.Kind() == reflect.Slice {
+		elems := make([]string, rv.Len())
+		for i := range elems {
+			elems[i] = fmt.Sprint(rv.Index(i).Interface())
+		}
+		err = sv.Replace(elems)
+	} else {
+		err = pf.Value.Set(fmt.Sprint(val))
+	}
+	if err != nil {
+		return fmt.Errorf("flag %q: invalid default value: %w", f.Long, err)
+	}
+	return nil
+}
+
 // GetFlag returns the flag with the given long name.
 func (f Flags) GetFlag(long string) (Flag, bool) {
 	for _, flag := range f {
@@ -67,7 +244,7 @@ func (f Flags) SetDefault(long string, val any) bool {
 
 // BuildFlags creates a slice of Flags from a struct.
 // It supports nested structs and will only generate flags if it finds a 'short' or 'long' tag.
-func BuildFlags(obj any) Flags {
+func BuildFlags(obj any, opts ...BuildFlagsOption) Flags {
 	v := reflect.ValueOf(obj)
 	if v.Kind() != reflect.Ptr {
 		panic(fmt.Errorf("expected a pointer, got %s", v.Kind()))
@@ -77,10 +254,45 @@ func BuildFlags(obj any) Flags {
 		panic(fmt.Errorf("expected a struct, got %s", v.Kind()))
 	}
 
-	return buildFlagsRecursive(v)
+	var o buildFlagsOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return buildFlagsRecursive(v, o, "")
 }
 
-func buildFlagsRecursive(v reflect.Value) Flags {
+// BuildFlagsWithPrefix creates a slice of Flags from a struct, like
+// BuildFlags, and namespaces them with the prefix. This allows the same options
+// struct to be shared by multiple commands without flag collisions. Long names
+// are prefixed with the prefix and a dash (e.g. "source-host"), config keys
+// with the prefix and a dot (e.g. "source.host"). Short names are dropped, as
+// they can't be namespaced.
+func BuildFlagsWithPrefix(obj any, prefix string, opts ...BuildFlagsOption) Flags {
+	flags := BuildFlags(obj, opts...)
+	if prefix == "" {
+		return flags
+	}
+	for i, f := range flags {
+		key := f.ConfigKey
+		if key == "" {
+			key = f.Long
+		}
+		f.ConfigKey = prefix + "." + key
+		f.Long = prefix + "-" + f.Long
+		f.Short = ""
+		if len(f.Aliases) > 0 {
+			aliases := make([]string, len(f.Aliases))
+			for j, alias := range f.Aliases {
+				aliases[j] = prefix + "-" + alias
+			}
+			f.Aliases = aliases
+		}
+		flags[i] = f
+	}
+	return flags
+}
+
+func buildFlagsRecursive(v reflect.Value, o buildFlagsOptions, keyPrefix string) Flags {
 	t := v.Type()
 	var flags Flags
 
@@ -94,16 +306,38 @@ func buildFlagsRecursive(v reflect.Value) Flags {
 			if err != nil {
 				panic(err)
 			}
+			if key, ok := mapstructureKey(field, keyPrefix); o.mapstructureKeys && ok {
+				flag.ConfigKey = key
+			}
 			flags = append(flags, flag)
 		} else if fieldValue.Kind() == reflect.Struct {
 			// If the field is a struct, recurse into it
-			embeddedFlags := buildFlagsRecursive(fieldValue)
+			prefix := keyPrefix
+			if key, ok := mapstructureKey(field, keyPrefix); ok {
+				prefix = key
+			}
+			embeddedFlags := buildFlagsRecursive(fieldValue, o, prefix)
 			flags = append(flags, embeddedFlags...)
 		}
 	}
 	return flags
 }
 
+// mapstructureKey returns the name in the mapstructure tag of the field,
+// prefixed with the prefix and a dot if the prefix is not empty. It returns
+// false if the field has no name in the mapstructure tag.
+func mapstructureKey(field reflect.StructField, prefix string) (string, bool) {
+	name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+	switch {
+	case name == "" || name == "-":
+		return "", false
+	case prefix == "":
+		return name, true
+	default:
+		return prefix + "." + name, true
+	}
+}
+
 func hasTag(tag reflect.StructTag, key string) bool {
 	_, ok := tag.Lookup(key)
 	return ok
@@ -117,6 +351,10 @@ func buildFlag(val reflect.Value, sf reflect.StructField) (Flag, error) {
 		tagNamePersistent = "persistent"
 		tagNameUsage      = "usage"
 		tagNameHidden     = "hidden"
+		tagNameEnv        = "env"
+		tagNameDeprecated = "deprecated"
+		tagNameSecret     = "secret"
+		tagNameAliases    = "aliases"
 	)
 
 	var (
@@ -126,6 +364,10 @@ func buildFlag(val reflect.Value, sf reflect.StructField) (Flag, error) {
 		persistent bool
 		usage      string
 		hidden     bool
+		envVars    []string
+		deprecated string
+		secret     bool
+		aliases    []string
 	)
 
 	if v, ok := sf.Tag.Lookup(tagNameLong); ok {
@@ -158,6 +400,26 @@ func buildFlag(val reflect.Value, sf reflect.StructField) (Flag, error) {
 			return Flag{}, fmt.Errorf("error parsing tag \"hidden\": %w", err)
 		}
 	}
+	if v, ok := sf.Tag.Lookup(tagNameEnv); ok {
+		envVars = strings.Split(v, ",")
+	}
+	if v, ok := sf.Tag.Lookup(tagNameDeprecated); ok {
+		deprecated = v
+	}
+	if v, ok := sf.Tag.Lookup(tagNameSecret); ok {
+		var err error
+		secret, err = strconv.ParseBool(v)
+		if err != nil {
+			return Flag{}, fmt.Errorf("error parsing tag \"secret\": %w", err)
+		}
+	}
+	if v, ok := sf.Tag.Lookup(tagNameAliases); ok {
+		for _, alias := range strings.Split(v, ",") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				aliases = append(aliases, alias)
+			}
+		}
+	}
 
 	return Flag{
 		Long:       long,
@@ -168,5 +430,345 @@ func buildFlag(val reflect.Value, sf reflect.StructField) (Flag, error) {
 		Default:    nil,
 		Ptr:        val.Addr().Interface(),
 		Hidden:     hidden,
+		EnvVars:    envVars,
+		Deprecated: deprecated,
+		Secret:     secret,
+		Aliases:    aliases,
 	}, nil
 }
+
+// ApplyFlagsWithValidator registers the flags on a flag set that is not
+// managed by Ecdysis (e.g. of a plain cobra command). It allows adopting Flag
+// incrementally. Flag.Persistent is ignored, as the flag set is chosen by the
+// caller.
+//
+// Applying the flags happens in two phases and the caller is responsible for
+// the second one: the returned validate function applies Flag.DefaultFunc and
+// runs the validations and transformations that need the parsed flags (e.g.
+// MustChange, PathExpand and the slice constraints). It has to be called after
+// the flag set is parsed (e.g. in the PreRunE of the command), otherwise those
+// options have no effect.
+func ApplyFlagsWithValidator(fs *pflag.FlagSet, flags Flags) (validate func() error, err error) {
+	var (
+		checks       []func() error
+		defaultFuncs []Flag
+	)
+	for _, f := range flags {
+		fchecks, err := applyFlag(fs, f)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, fchecks...)
+		if f.DefaultFunc != nil {
+			defaultFuncs = append(defaultFuncs, f)
+		}
+	}
+
+	return func() error {
+		// defaults are computed before the checks, so they are validated
+		for _, f := range defaultFuncs {
+			if err := f.applyDefaultFunc(fs); err != nil {
+				return err
+			}
+		}
+		for _, check := range checks {
+			if err := check(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// applyFlag registers the flag on the flag set. It returns the validations
+// that need to be executed after the flags are parsed.
+//
+//nolint:funlen,gocyclo,gocognit,forcetypeassert // this function has a big switch statement, can't get around that
+func applyFlag(flags *pflag.FlagSet, f Flag) ([]func() error, error) {
+	// checks are validations executed after the flags are parsed.
+	var checks []func() error
+
+	if _, ok := f.Ptr.(*string); f.Secret && !ok {
+		return nil, fmt.Errorf("flag %q: Secret is only supported for string flags", f.Long)
+	}
+
+	if f.Required {
+		f.Usage += " (required)"
+	}
+
+	switch val := f.Ptr.(type) {
+	case pflag.Value:
+		if f.Default != nil {
+			if err := val.Set(fmt.Sprint(f.Default)); err != nil {
+				return nil, fmt.Errorf("flag %q: invalid default value: %w", f.Long, err)
+			}
+		}
+		flags.VarP(val, f.Long, f.Short, f.Usage)
+	case *string:
+		if f.Default == nil {
+			f.Default = ""
+		}
+		flags.StringVarP(val, f.Long, f.Short, f.Default.(string), f.Usage)
+	case *int:
+		if f.Default == nil {
+			f.Default = 0
+		}
+		flags.IntVarP(val, f.Long, f.Short, f.Default.(int), f.Usage)
+	case *int8:
+		if f.Default == nil {
+			f.Default = int8(0)
+		}
+		flags.Int8VarP(val, f.Long, f.Short, f.Default.(int8), f.Usage)
+	case *int16:
+		if f.Default == nil {
+			f.Default = int16(0)
+		}
+		flags.Int16VarP(val, f.Long, f.Short, f.Default.(int16), f.Usage)
+	case *int32:
+		if f.Default == nil {
+			f.Default = int32(0)
+		}
+		flags.Int32VarP(val, f.Long, f.Short, f.Default.(int32), f.Usage)
+	case *int64:
+		if f.Default == nil {
+			f.Default = int64(0)
+		}
+		flags.Int64VarP(val, f.Long, f.Short, f.Default.(int64), f.Usage)
+	case *float32:
+		if f.Default == nil {
+			f.Default = float32(0)
+		}
+		flags.Float32VarP(val, f.Long, f.Short, f.Default.(float32), f.Usage)
+	case *float64:
+		if f.Default == nil {
+			f.Default = float64(0)
+		}
+		flags.Float64VarP(val, f.Long, f.Short, f.Default.(float64), f.Usage)
+	case *bool:
+		if f.Default == nil {
+			f.Default = false
+		}
+		flags.BoolVarP(val, f.Long, f.Short, f.Default.(bool), f.Usage)
+	case *time.Duration:
+		if f.Default == nil {
+			f.Default = time.Duration(0)
+		}
+		flags.DurationVarP(val, f.Long, f.Short, f.Default.(time.Duration), f.Usage)
+	case *[]bool:
+		if f.Default == nil {
+			f.Default = []bool(nil)
+		}
+		flags.BoolSliceVarP(val, f.Long, f.Short, f.Default.([]bool), f.Usage)
+	case *[]float32:
+		if f.Default == nil {
+			f.Default = []float32(nil)
+		}
+		flags.Float32SliceVarP(val, f.Long, f.Short, f.Default.([]float32), f.Usage)
+	case *[]float64:
+		if f.Default == nil {
+			f.Default = []float64(nil)
+		}
+		flags.Float64SliceVarP(val, f.Long, f.Short, f.Default.([]float64), f.Usage)
+	case *[]int32:
+		if f.Default == nil {
+			f.Default = []int32(nil)
+		}
+		flags.Int32SliceVarP(val, f.Long, f.Short, f.Default.([]int32), f.Usage)
+	case *[]int64:
+		if f.Default == nil {
+			f.Default = []int64(nil)
+		}
+		flags.Int64SliceVarP(val, f.Long, f.Short, f.Default.([]int64), f.Usage)
+	case *[]int:
+		if f.Default == nil {
+			f.Default = []int(nil)
+		}
+		flags.IntSliceVarP(val, f.Long, f.Short, f.Default.([]int), f.Usage)
+	case *[]string:
+		if f.Default == nil {
+			f.Default = []string(nil)
+		}
+		flags.StringSliceVarP(val, f.Long, f.Short, f.Default.([]string), f.Usage)
+	default:
+		return nil, fmt.Errorf("unexpected flag value type: %T", val)
+	}
+
+	if f.MustChange {
+		// The default is captured before it is possibly seeded from the
+		// environment, a value from the environment counts as a change.
+		pf := flags.Lookup(f.Long)
+		def := pf.DefValue
+		checks = append(checks, func() error {
+			if pf.Value.String() == def {
+				return fmt.Errorf("flag %q: must be changed from its default value %q", f.Long, def)
+			}
+			return nil
+		})
+	}
+
+	seeded, err := seedFlagFromEnv(flags, f)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Secret {
+		// The default value is shown in the help and could be seeded
+		// from the environment, it must not be revealed.
+		flags.Lookup(f.Long).DefValue = ""
+	}
+
+	// A flag seeded from the environment already has a value.
+	if f.Required && !seeded {
+		err := cobra.MarkFlagRequired(flags, f.Long)
+		if err != nil {
+			return nil, fmt.Errorf("could not mark flag required: %w", err)
+		}
+	}
+
+	if f.Hidden {
+		err := flags.MarkHidden(f.Long)
+		if err != nil {
+			return nil, fmt.Errorf("could not mark flag hidden: %w", err)
+		}
+	}
+
+	if f.Deprecated != "" {
+		err := flags.MarkDeprecated(f.Long, f.Deprecated)
+		if err != nil {
+			return nil, fmt.Errorf("could not mark flag deprecated: %w", err)
+		}
+	}
+
+	if f.ConfigKey != "" && f.ConfigKey != f.Long {
+		err := flags.SetAnnotation(f.Long, configKeyAnnotation, []string{f.ConfigKey})
+		if err != nil {
+			return nil, fmt.Errorf("could not set config key of flag: %w", err)
+		}
+	}
+
+	for _, alias := range f.Aliases {
+		if flags.Lookup(alias) != nil {
+			return nil, fmt.Errorf("flag %q: alias %q conflicts with an existing flag", f.Long, alias)
+		}
+		pf := flags.Lookup(f.Long)
+		flags.Var(&flagAlias{target: pf}, alias, fmt.Sprintf("alias of --%s", f.Long))
+		af := flags.Lookup(alias)
+		af.NoOptDefVal = pf.NoOptDefVal // e.g. boolean flags don't require a value
+		af.Hidden = true
+	}
+
+	if f.PathExpand {
+		ptr, ok := f.Ptr.(*string)
+		if !ok {
+			return nil, fmt.Errorf("flag %q: PathExpand is only supported for string flags", f.Long)
+		}
+		checks = append(checks, func() error {
+			if *ptr == "" {
+				return nil
+			}
+			path, err := expandPath(*ptr)
+			if err != nil {
+				return fmt.Errorf("flag %q: invalid path: %w", f.Long, err)
+			}
+			*ptr = path
+			return nil
+		})
+	}
+
+	if f.hasSliceConstraints() {
+		if reflect.TypeOf(f.Ptr).Elem().Kind() != reflect.Slice {
+			return nil, fmt.Errorf("flag %q: MinLen, MaxLen and ValidateElem are only supported for slice flags", f.Long)
+		}
+		checks = append(checks, f.validateSlice)
+	}
+
+	return checks, nil
+}
+
+// seedFlagFromEnv sets the default value of the flag to the value of the first
+// environment variable in Flag.EnvVars that is set. It returns true if the flag
+// was seeded.
+func seedFlagFromEnv(flags *pflag.FlagSet, f Flag) (bool, error) {
+	env, val, ok := f.lookupEnv()
+	if !ok {
+		return false, nil
+	}
+
+	if _, isBool := f.Ptr.(*bool); isBool {
+		b, err := parseTruthy(val)
+		if err != nil {
+			return false, fmt.Errorf("flag %q: invalid value from environment variable %s: %w", f.Long, env, err)
+		}
+		val = strconv.FormatBool(b)
+	}
+
+	pf := flags.Lookup(f.Long)
+	var err error
+	if sv, ok := pf.Value.(pflag.SliceValue); ok {
+		// Replace doesn't mark the slice as changed, so values supplied on the
+		// command line still replace the default instead of appending to it.
+		err = sv.Replace(strings.Split(val, ","))
+	} else {
+		err = pf.Value.Set(val)
+	}
+	if err != nil {
+		return false, fmt.Errorf("flag %q: invalid value from environment variable %s: %w", f.Long, env, err)
+	}
+	pf.DefValue = pf.Value.String()
+
+	return true, nil
+}
+
+// flagAlias is the value of a flag that is an alias of another flag. Setting it
+// sets the other flag and marks it as changed, so it behaves as if the other
+// flag was supplied.
+type flagAlias struct {
+	target *pflag.Flag
+}
+
+func (a *flagAlias) String() string { return a.target.Value.String() }
+func (a *flagAlias) Type() string   { return a.target.Value.Type() }
+func (a *flagAlias) Set(s string) error {
+	if err := a.target.Value.Set(s); err != nil {
+		return err
+	}
+	a.target.Changed = true
+	return nil
+}
+
+// expandPath expands a leading "~" to the home directory of the user and
+// returns the absolute path.
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return filepath.Abs(path)
+}
+
+// lookupEnv returns the value of the first environment variable in EnvVars
+// that is set.
+func (f Flag) lookupEnv() (name, value string, ok bool) {
+	for _, name := range f.EnvVars {
+		if value, ok := os.LookupEnv(name); ok {
+			return name, value, true
+		}
+	}
+	return "", "", false
+}
+
+// parseTruthy parses a boolean value, accepting common truthy and falsy
+// spellings used in environment variables.
+func parseTruthy(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true, nil
+	case "", "0", "f", "false", "n", "no", "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid boolean value %q", s)
+	}
+}
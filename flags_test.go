@@ -15,10 +15,14 @@
 package ecdysis
 
 import (
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/pflag"
 )
 
 type testFlags struct {
@@ -69,3 +73,306 @@ func TestBuildFlags(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+type testCmdWithEnum struct {
+	flags struct {
+		Format Enum `long:"format" short:"f" usage:"output format"`
+	}
+}
+
+var _ CommandWithFlags = (*testCmdWithEnum)(nil)
+
+func (c *testCmdWithEnum) Usage() string { return "export" }
+func (c *testCmdWithEnum) Flags() []Flag {
+	c.flags.Format = NewEnum("text", "text", "json", "yaml")
+	return BuildFlags(&c.flags)
+}
+
+func TestEnum(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{{
+		name: "default",
+		args: []string{},
+		want: "text",
+	}, {
+		name: "allowed",
+		args: []string{"--format", "json"},
+		want: "json",
+	}, {
+		name:    "not allowed",
+		args:    []string{"--format", "xml"},
+		wantErr: `invalid argument "xml" for "-f, --format" flag: must be one of [text, json, yaml]`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &testCmdWithEnum{}
+			cmd := New().MustBuildCobraCommand(c)
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := c.flags.Format.Value; got != tc.want {
+				t.Fatalf("expected value %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+type testConnectionFlags struct {
+	Host string `long:"host" short:"H" usage:"host to connect to"`
+	Port int    `long:"port" usage:"port to connect to"`
+}
+
+type testCmdWithPrefixedFlags struct {
+	source testConnectionFlags
+	dest   testConnectionFlags
+}
+
+var _ CommandWithFlags = (*testCmdWithPrefixedFlags)(nil)
+
+func (c *testCmdWithPrefixedFlags) Usage() string { return "copy" }
+func (c *testCmdWithPrefixedFlags) Flags() []Flag {
+	return append(
+		BuildFlagsWithPrefix(&c.source, "source"),
+		BuildFlagsWithPrefix(&c.dest, "dest")...,
+	)
+}
+
+func TestBuildFlagsWithPrefix(t *testing.T) {
+	c := &testCmdWithPrefixedFlags{}
+
+	want := Flags{
+		{Long: "source-host", Usage: "host to connect to", Ptr: &c.source.Host, ConfigKey: "source.host"},
+		{Long: "source-port", Usage: "port to connect to", Ptr: &c.source.Port, ConfigKey: "source.port"},
+		{Long: "dest-host", Usage: "host to connect to", Ptr: &c.dest.Host, ConfigKey: "dest.host"},
+		{Long: "dest-port", Usage: "port to connect to", Ptr: &c.dest.Port, ConfigKey: "dest.port"},
+	}
+	if diff := cmp.Diff(want, Flags(c.Flags())); diff != "" {
+		t.Fatal(diff)
+	}
+
+	cmd := New().MustBuildCobraCommand(c)
+	cmd.SetArgs([]string{"--source-host", "a", "--dest-host", "b", "--dest-port", "8080"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.source.Host != "a" || c.dest.Host != "b" || c.dest.Port != 8080 {
+		t.Fatalf("unexpected flag values: source=%+v dest=%+v", c.source, c.dest)
+	}
+}
+
+func TestBuildFlagsWithPrefix_MapstructureConfigKeys(t *testing.T) {
+	var flags struct {
+		Host string `long:"host" mapstructure:"address"`
+	}
+
+	got := BuildFlagsWithPrefix(&flags, "source", WithMapstructureConfigKeys())
+	if len(got) != 1 || got[0].Long != "source-host" || got[0].ConfigKey != "source.address" {
+		t.Fatalf("unexpected flags: %+v", got)
+	}
+}
+
+func TestApplyFlagsWithValidator(t *testing.T) {
+	var opts struct {
+		Name    string        `long:"name" short:"n" usage:"name of the pipeline"`
+		Timeout time.Duration `long:"timeout" usage:"request timeout"`
+		Tags    []string      `long:"tag" usage:"tags to apply"`
+	}
+	flags := BuildFlags(&opts)
+	flags.SetDefault("timeout", 5*time.Second)
+
+	fs := pflag.NewFlagSet("standalone", pflag.ContinueOnError)
+	if _, err := ApplyFlagsWithValidator(fs, flags); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := fs.Lookup("timeout").DefValue; got != "5s" {
+		t.Fatalf("expected default value %q, got %q", "5s", got)
+	}
+
+	err := fs.Parse([]string{"-n", "my-pipeline", "--tag", "a", "--tag", "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Name != "my-pipeline" {
+		t.Fatalf("expected name %q, got %q", "my-pipeline", opts.Name)
+	}
+	if opts.Timeout != 5*time.Second {
+		t.Fatalf("expected timeout %v, got %v", 5*time.Second, opts.Timeout)
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, opts.Tags); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestApplyFlagsWithValidator_Validate(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{{
+		name: "default func",
+		args: []string{},
+		want: filepath.Join(home, "computed"),
+	}, {
+		name: "path expanded",
+		args: []string{"--output", "~/out"},
+		want: filepath.Join(home, "out"),
+	}, {
+		name:    "must change",
+		args:    []string{"--output", "out", "--token", "CHANGEME"},
+		wantErr: `flag "token": must be changed from its default value "CHANGEME"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var output, token string
+			flags := Flags{{
+				Long:        "output",
+				Ptr:         &output,
+				PathExpand:  true,
+				DefaultFunc: func(*pflag.FlagSet) any { return "~/computed" },
+			}, {
+				Long:    "token",
+				Ptr:     &token,
+				Default: "CHANGEME",
+			}}
+			if tc.wantErr != "" {
+				flags[1].MustChange = true
+			}
+
+			fs := pflag.NewFlagSet("standalone", pflag.ContinueOnError)
+			validate, err := ApplyFlagsWithValidator(fs, flags)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = validate()
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output != tc.want {
+				t.Fatalf("expected output %q, got %q", tc.want, output)
+			}
+		})
+	}
+}
+
+func TestApplyFlagsWithValidator_InvalidType(t *testing.T) {
+	var v complex64
+	fs := pflag.NewFlagSet("standalone", pflag.ContinueOnError)
+
+	_, err := ApplyFlagsWithValidator(fs, Flags{{Long: "complex", Ptr: &v}})
+	if err == nil || err.Error() != "unexpected flag value type: *complex64" {
+		t.Fatalf("expected unexpected type error, got %v", err)
+	}
+}
+
+func TestFlagAliases(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		want string
+	}{{
+		name: "canonical",
+		args: []string{"--pipeline-id", "canonical"},
+		want: "canonical",
+	}, {
+		name: "alias",
+		args: []string{"--id", "alias"},
+		want: "alias",
+	}, {
+		name: "last set wins",
+		args: []string{"--pipeline-id", "canonical", "--id", "alias"},
+		want: "alias",
+	}, {
+		name: "last set wins canonical",
+		args: []string{"--id", "alias", "--pipeline-id", "canonical"},
+		want: "canonical",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts struct {
+				PipelineID string `long:"pipeline-id" aliases:"id, pipeline,," usage:"pipeline ID"`
+			}
+			fs := pflag.NewFlagSet("standalone", pflag.ContinueOnError)
+			if _, err := ApplyFlagsWithValidator(fs, BuildFlags(&opts)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts.PipelineID != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, opts.PipelineID)
+			}
+			if !fs.Changed("pipeline-id") {
+				t.Fatal("expected canonical flag to be marked as changed")
+			}
+			if !fs.Lookup("id").Hidden {
+				t.Fatal("expected alias to be hidden")
+			}
+		})
+	}
+}
+
+func TestFlagAliases_Conflict(t *testing.T) {
+	var opts struct {
+		Name string `long:"name"`
+		ID   string `long:"id" aliases:"name"`
+	}
+	fs := pflag.NewFlagSet("standalone", pflag.ContinueOnError)
+	_, err := ApplyFlagsWithValidator(fs, BuildFlags(&opts))
+	want := `flag "id": alias "name" conflicts with an existing flag`
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, got %v", want, err)
+	}
+}
+
+func TestFlagAliases_Bool(t *testing.T) {
+	var opts struct {
+		Force bool `long:"force" aliases:"yes"`
+	}
+	fs := pflag.NewFlagSet("standalone", pflag.ContinueOnError)
+	if _, err := ApplyFlagsWithValidator(fs, BuildFlags(&opts)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := fs.Parse([]string{"--yes"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.Force {
+		t.Fatal("expected flag to be set using the alias")
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// OutputFormat is the format in which a command writes its output.
+type OutputFormat string
+
+const (
+	OutputFormatText OutputFormat = "text"
+	OutputFormatJSON OutputFormat = "json"
+	OutputFormatYAML OutputFormat = "yaml"
+)
+
+// Output is used by commands to write output to the user.
+type Output interface {
+	// Stdout writes the message to the standard output.
+	Stdout(msg any)
+	// Stderr writes the message to the standard error output.
+	Stderr(msg any)
+}
+
+// DefaultOutput is the default implementation of Output, which writes
+// messages formatted using fmt.Fprint.
+type DefaultOutput struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var _ Output = (*DefaultOutput)(nil)
+
+// NewDefaultOutput returns an Output writing to the output and error output of
+// the cobra command.
+func NewDefaultOutput(cmd *cobra.Command) *DefaultOutput {
+	return NewOutput(cmd.OutOrStdout(), cmd.ErrOrStderr())
+}
+
+// NewOutput returns an Output writing to the provided writers, independent of
+// any cobra command. It is useful when embedding the CLI (e.g. in a server or
+// in tests), install it using WithOutput:
+//
+//	ecdysis.New(ecdysis.WithOutput(func(*cobra.Command) ecdysis.Output {
+//		return ecdysis.NewOutput(stdout, stderr)
+//	}))
+func NewOutput(stdout, stderr io.Writer) *DefaultOutput {
+	return &DefaultOutput{
+		stdout: stdout,
+		stderr: stderr,
+	}
+}
+
+// Stdout writes the message to the standard output.
+func (d *DefaultOutput) Stdout(msg any) {
+	_, _ = fmt.Fprint(d.stdout, msg)
+}
+
+// Stderr writes the message to the standard error output.
+func (d *DefaultOutput) Stderr(msg any) {
+	_, _ = fmt.Fprint(d.stderr, msg)
+}
+
+// Confirm writes the prompt followed by " [y/N]: " to the standard output and
+// reads the answer from the reader. It returns true if the answer is "y" or
+// "yes" (ignoring case and surrounding whitespace). Any other answer, including
+// an empty answer or reaching the end of the input, is treated as "no".
+func (d *DefaultOutput) Confirm(prompt string, reader io.Reader) (bool, error) {
+	d.Stdout(prompt + " [y/N]: ")
+
+	input, err := bufio.NewReader(reader).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("failed to read user input: %w", err)
+	}
+
+	input = strings.TrimRight(input, "\r\n")
+	confirm := CommandWithConfirmDecorator{TrimSpace: true, CaseInsensitive: true}
+	return confirm.matches(input, "y") || confirm.matches(input, "yes"), nil
+}
+
+// TeeOutput is an Output that duplicates messages to multiple sinks (e.g. the
+// terminal and an audit log file). A failing sink doesn't stop the message
+// from being written to the other sinks.
+type TeeOutput struct {
+	stdout []io.Writer
+	stderr []io.Writer
+}
+
+var _ Output = (*TeeOutput)(nil)
+
+// NewTeeOutput returns an Output writing to the output and error output of the
+// cobra command, as well as to all sinks. Messages written to the standard
+// output and standard error output are both written to the sinks.
+func NewTeeOutput(cmd *cobra.Command, sinks ...io.Writer) *TeeOutput {
+	return &TeeOutput{
+		stdout: append([]io.Writer{cmd.OutOrStdout()}, sinks...),
+		stderr: append([]io.Writer{cmd.ErrOrStderr()}, sinks...),
+	}
+}
+
+// Stdout writes the message to the standard output and all sinks.
+func (t *TeeOutput) Stdout(msg any) {
+	for _, w := range t.stdout {
+		_, _ = fmt.Fprint(w, msg)
+	}
+}
+
+// Stderr writes the message to the standard error output and all sinks.
+func (t *TeeOutput) Stderr(msg any) {
+	for _, w := range t.stderr {
+		_, _ = fmt.Fprint(w, msg)
+	}
+}
+
+// clearScreen is the ANSI escape sequence that moves the cursor to the top left
+// corner and clears the screen.
+const clearScreen = "\033[H\033[2J"
+
+// outputFlagName is the name of the flag used to select the output format.
+const outputFlagName = "output"
+
+// outputFormatFromCommand returns the output format selected using the flag
+// --output. If the command doesn't have the flag, OutputFormatText is returned.
+func outputFormatFromCommand(cmd *cobra.Command) OutputFormat {
+	f := cmd.Flags().Lookup(outputFlagName)
+	if f == nil || f.Value.String() == "" {
+		return OutputFormatText
+	}
+	return OutputFormat(strings.ToLower(f.Value.String()))
+}
+
+// isTerminal returns true if the writer is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
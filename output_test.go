@@ -0,0 +1,115 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/spf13/cobra"
+)
+
+func TestDefaultOutput_Confirm(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{name: "y", input: "y\n", want: true},
+		{name: "yes", input: "yes\n", want: true},
+		{name: "yes padded and uppercase", input: "  YES \r\n", want: true},
+		{name: "yes without newline", input: "yes", want: true},
+		{name: "n", input: "n\n", want: false},
+		{name: "no", input: "no\n", want: false},
+		{name: "empty", input: "\n", want: false},
+		{name: "other", input: "sure\n", want: false},
+		{name: "EOF", input: "", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			out := &DefaultOutput{stdout: &stdout}
+
+			got, err := out.Confirm("Delete pipeline?", strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			if stdout.String() != "Delete pipeline? [y/N]: " {
+				t.Fatalf("unexpected prompt %q", stdout.String())
+			}
+		})
+	}
+}
+
+func TestDefaultOutput_Confirm_ReadError(t *testing.T) {
+	wantErr := errors.New("broken pipe")
+	out := &DefaultOutput{stdout: &bytes.Buffer{}}
+
+	_, err := out.Confirm("Delete pipeline?", iotest.ErrReader(wantErr))
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected error %v, got %v", wantErr, err)
+	}
+}
+
+func TestTeeOutput(t *testing.T) {
+	cmd := &cobra.Command{}
+	var stdout, stderr, sink bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+
+	// the failing sink must not stop the other sinks
+	failing := writerFunc(func([]byte) (int, error) {
+		return 0, errors.New("disk full")
+	})
+	out := NewTeeOutput(cmd, failing, &sink)
+	out.Stdout("result\n")
+	out.Stderr("warning\n")
+
+	if stdout.String() != "result\n" {
+		t.Fatalf("expected stdout %q, got %q", "result\n", stdout.String())
+	}
+	if stderr.String() != "warning\n" {
+		t.Fatalf("expected stderr %q, got %q", "warning\n", stderr.String())
+	}
+	if sink.String() != "result\nwarning\n" {
+		t.Fatalf("expected sink %q, got %q", "result\nwarning\n", sink.String())
+	}
+}
+
+func TestNewOutput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	out := NewOutput(&stdout, &stderr)
+	out.Stdout("result\n")
+	out.Stderr("warning\n")
+
+	if stdout.String() != "result\n" {
+		t.Fatalf("expected stdout %q, got %q", "result\n", stdout.String())
+	}
+	if stderr.String() != "warning\n" {
+		t.Fatalf("expected stderr %q, got %q", "warning\n", stderr.String())
+	}
+}
+
+// writerFunc is an io.Writer calling the function.
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
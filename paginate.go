@@ -0,0 +1,92 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrPageLimitReached is returned by Paginate when the page limit is reached
+// (see WithPageLimit) and more pages are available. The items of the fetched
+// pages were passed to each, callers can use errors.Is to treat the result
+// as truncated instead of failed.
+var ErrPageLimitReached = errors.New("page limit reached")
+
+// PaginateOption is a function type that configures Paginate.
+type PaginateOption func(*paginateOptions)
+
+type paginateOptions struct {
+	pageLimit int
+}
+
+// WithPageLimit limits the number of pages fetched by Paginate. Zero means no
+// limit.
+func WithPageLimit(limit int) PaginateOption {
+	return func(o *paginateOptions) {
+		o.pageLimit = limit
+	}
+}
+
+// Paginate fetches pages from a cursor based API and calls each for every
+// item. The first page is fetched with an empty cursor, every following page
+// with the cursor returned by the previous call to fetch. Pagination stops
+// when fetch returns an empty cursor, the page limit is reached (see
+// WithPageLimit and ErrPageLimitReached) or the context is cancelled. Errors
+// returned by fetch or each stop the pagination and are returned. If fetch
+// returns a cursor it returned before, pagination stops with an error instead
+// of looping forever.
+func Paginate[T any](
+	ctx context.Context,
+	fetch func(ctx context.Context, cursor string) (items []T, next string, err error),
+	each func(T) error,
+	opts ...PaginateOption,
+) error {
+	var o paginateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var cursor string
+	seen := make(map[string]bool)
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		items, next, err := fetch(ctx, cursor)
+		if err != nil {
+			return fmt.Errorf("failed to fetch page %d: %w", page, err)
+		}
+		for _, item := range items {
+			if err := each(item); err != nil {
+				return err
+			}
+		}
+
+		if next == "" {
+			return nil
+		}
+		if seen[next] {
+			return fmt.Errorf("page %d returned the cursor %q of a previous page", page, next)
+		}
+		if o.pageLimit > 0 && page >= o.pageLimit {
+			return fmt.Errorf("stopped after %d pages: %w", page, ErrPageLimitReached)
+		}
+		seen[next] = true
+		cursor = next
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakePages returns a fetch function serving the pages, cursors are the index
+// of the next page.
+func fakePages(pages [][]int, cursors *[]string) func(context.Context, string) ([]int, string, error) {
+	next := map[string]string{}
+	byCursor := map[string][]int{}
+	cursor := ""
+	for i, page := range pages {
+		byCursor[cursor] = page
+		if i < len(pages)-1 {
+			next[cursor] = string(rune('a' + i))
+			cursor = next[cursor]
+		}
+	}
+
+	return func(_ context.Context, cursor string) ([]int, string, error) {
+		*cursors = append(*cursors, cursor)
+		page, ok := byCursor[cursor]
+		if !ok {
+			return nil, "", errors.New("unknown cursor")
+		}
+		return page, next[cursor], nil
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	var cursors []string
+	fetch := fakePages([][]int{{1, 2}, {3}, {4, 5}}, &cursors)
+
+	var got []int
+	err := Paginate(context.Background(), fetch, func(i int) error {
+		got = append(got, i)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff([]int{1, 2, 3, 4, 5}, got); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff([]string{"", "a", "b"}, cursors); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestPaginate_PageLimit(t *testing.T) {
+	var cursors []string
+	fetch := fakePages([][]int{{1, 2}, {3}, {4, 5}}, &cursors)
+
+	var got []int
+	err := Paginate(context.Background(), fetch, func(i int) error {
+		got = append(got, i)
+		return nil
+	}, WithPageLimit(2))
+	if !errors.Is(err, ErrPageLimitReached) || err.Error() != "stopped after 2 pages: page limit reached" {
+		t.Fatalf("expected page limit error, got %v", err)
+	}
+
+	if diff := cmp.Diff([]int{1, 2, 3}, got); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// no error if the last page is within the limit
+	cursors = nil
+	err = Paginate(context.Background(), fakePages([][]int{{1, 2}, {3}}, &cursors), func(int) error {
+		return nil
+	}, WithPageLimit(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPaginate_RepeatedCursor(t *testing.T) {
+	var got []int
+	err := Paginate(context.Background(), func(_ context.Context, cursor string) ([]int, string, error) {
+		// the API keeps returning the same cursor
+		return []int{len(cursor)}, "a", nil
+	}, func(i int) error {
+		got = append(got, i)
+		return nil
+	})
+	if err == nil || err.Error() != `page 2 returned the cursor "a" of a previous page` {
+		t.Fatalf("expected repeated cursor error, got %v", err)
+	}
+
+	if diff := cmp.Diff([]int{0, 1}, got); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestPaginate_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var cursors []string
+	fetch := fakePages([][]int{{1, 2}, {3}, {4, 5}}, &cursors)
+
+	err := Paginate(ctx, fetch, func(i int) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if diff := cmp.Diff([]string{""}, cursors); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestPaginate_Errors(t *testing.T) {
+	wantErr := errors.New("boom")
+
+	err := Paginate(context.Background(), func(context.Context, string) ([]int, string, error) {
+		return nil, "", wantErr
+	}, func(int) error { return nil })
+	if !errors.Is(err, wantErr) || err.Error() != "failed to fetch page 1: boom" {
+		t.Fatalf("expected fetch error, got %v", err)
+	}
+
+	var cursors []string
+	err = Paginate(context.Background(), fakePages([][]int{{1, 2}, {3}}, &cursors), func(int) error {
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected each error, got %v", err)
+	}
+	if diff := cmp.Diff([]string{""}, cursors); diff != "" {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// spinnerFrames are the frames of the spinner rendered in text mode.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Progress reports the progress of a long-running operation. If the output
+// format of the command is OutputFormatJSON, each update is written as a JSON
+// object on a separate line (NDJSON) to the standard output, e.g.
+// {"progress":0.5,"message":"copying"}. Otherwise, a spinner followed by the
+// percentage and message is rendered on a single line in the error output,
+// keeping the standard output clean. Progress is safe for concurrent use.
+type Progress struct {
+	format OutputFormat
+	stdout io.Writer
+	stderr io.Writer
+
+	mu    sync.Mutex
+	frame int
+}
+
+// progressEvent is a progress update written in JSON mode.
+type progressEvent struct {
+	Progress float64 `json:"progress"`
+	Message  string  `json:"message"`
+}
+
+// NewProgress returns a Progress writing to the output streams of the command
+// in the output format selected by the flag --output.
+func NewProgress(cmd *cobra.Command) *Progress {
+	return &Progress{
+		format: outputFormatFromCommand(cmd),
+		stdout: cmd.OutOrStdout(),
+		stderr: cmd.ErrOrStderr(),
+	}
+}
+
+// Update reports the progress as a fraction between 0 and 1, along with a
+// message describing the current step.
+func (p *Progress) Update(progress float64, message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	progress = min(max(progress, 0), 1)
+	if p.format == OutputFormatJSON {
+		p.writeJSON(progress, message)
+		return
+	}
+
+	frame := spinnerFrames[p.frame%len(spinnerFrames)]
+	p.frame++
+	_, _ = fmt.Fprintf(p.stderr, "\r\033[K%s %3.0f%% %s", frame, progress*100, message)
+}
+
+// Done reports that the operation finished. In text mode, the spinner is
+// replaced with the message.
+func (p *Progress) Done(message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.format == OutputFormatJSON {
+		p.writeJSON(1, message)
+		return
+	}
+	_, _ = fmt.Fprintf(p.stderr, "\r\033[K✓ %s\n", message)
+}
+
+func (p *Progress) writeJSON(progress float64, message string) {
+	_ = json.NewEncoder(p.stdout).Encode(progressEvent{
+		Progress: progress,
+		Message:  message,
+	})
+}
@@ -0,0 +1,70 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+)
+
+func newTestProgress(t *testing.T, format OutputFormat) (*Progress, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	cmd := &cobra.Command{Use: "copy"}
+	cmd.Flags().String(outputFlagName, string(format), "output format")
+
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	return NewProgress(cmd), &stdout, &stderr
+}
+
+func TestProgress_JSON(t *testing.T) {
+	p, stdout, stderr := newTestProgress(t, OutputFormatJSON)
+
+	p.Update(0, "starting")
+	p.Update(0.5, "copying")
+	p.Done("finished")
+
+	want := `{"progress":0,"message":"starting"}` + "\n" +
+		`{"progress":0.5,"message":"copying"}` + "\n" +
+		`{"progress":1,"message":"finished"}` + "\n"
+	if diff := cmp.Diff(want, stdout.String()); diff != "" {
+		t.Fatal(diff)
+	}
+	if stderr.Len() != 0 {
+		t.Fatalf("expected no output on stderr, got %q", stderr.String())
+	}
+}
+
+func TestProgress_Text(t *testing.T) {
+	p, stdout, stderr := newTestProgress(t, OutputFormatText)
+
+	p.Update(0.25, "copying")
+	p.Update(2, "still copying")
+	p.Done("finished")
+
+	want := "\r\033[K⠋  25% copying" +
+		"\r\033[K⠙ 100% still copying" +
+		"\r\033[K✓ finished\n"
+	if diff := cmp.Diff(want, stderr.String()); diff != "" {
+		t.Fatal(diff)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no output on stdout, got %q", stdout.String())
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryOption is a function type that configures RetryableHTTPClient.
+type RetryOption func(*retryTransport)
+
+// WithMaxRetries sets the maximum number of retries of a request. Defaults to
+// 3.
+func WithMaxRetries(n int) RetryOption {
+	return func(t *retryTransport) {
+		t.maxRetries = n
+	}
+}
+
+// WithBackoff sets the minimum and maximum wait time between retries. The wait
+// time starts at minimum and is doubled after each retry, up to maximum.
+// Defaults to 500ms and 30s.
+func WithBackoff(minimum, maximum time.Duration) RetryOption {
+	return func(t *retryTransport) {
+		t.minBackoff = minimum
+		t.maxBackoff = maximum
+	}
+}
+
+// RetryableHTTPClient returns a copy of the client that retries idempotent
+// requests (GET, HEAD, OPTIONS, TRACE, PUT and DELETE) which fail with a
+// network error or respond with status 429 or 5xx. The wait time between
+// retries is taken from the Retry-After header if present (limited to the
+// maximum backoff), otherwise it grows exponentially (see WithBackoff).
+// Waiting stops when the request context is cancelled. If client is nil,
+// http.DefaultClient is used.
+//
+//	client := ecdysis.RetryableHTTPClient(http.DefaultClient)
+//	resp, err := client.Do(req.WithContext(ctx))
+func RetryableHTTPClient(client *http.Client, opts ...RetryOption) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	t := &retryTransport{
+		next:       client.Transport,
+		maxRetries: 3,
+		minBackoff: 500 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+	}
+	if t.next == nil {
+		t.next = http.DefaultTransport
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	c := *client
+	c.Transport = t
+	return &c
+}
+
+// retryTransport is an http.RoundTripper that retries requests.
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// RoundTrip executes the request and retries it if it is retryable.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.minBackoff
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err //nolint:wrapcheck // error comes from the caller
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.maxRetries || !isRetryableRequest(req) || !isRetryableResponse(resp, err) {
+			return resp, err //nolint:wrapcheck // the transport is transparent
+		}
+
+		wait := backoff
+		if resp != nil {
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				// the server must not make the client wait arbitrarily long
+				wait = min(d, t.maxBackoff)
+			}
+			// drain and close the body to reuse the connection
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		backoff = min(backoff*2, t.maxBackoff)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// isRetryableRequest returns true if the request is idempotent and can be sent
+// again.
+func isRetryableRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	// requests with a body can only be retried if the body can be recreated
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// isRetryableResponse returns true if the request failed with an error or the
+// response status indicates a temporary failure.
+func isRetryableResponse(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// parseRetryAfter parses the value of the Retry-After header, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
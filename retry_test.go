@@ -0,0 +1,162 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer returns a server responding with the statuses in order, the
+// last status is repeated.
+func newFlakyServer(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		i := int(calls.Add(1)) - 1
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(statuses[min(i, len(statuses)-1)])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRetryableHTTPClient(t *testing.T) {
+	testCases := []struct {
+		name      string
+		method    string
+		header    http.Header
+		statuses  []int
+		want      int
+		wantCalls int32
+	}{{
+		name:      "429 with Retry-After",
+		method:    http.MethodGet,
+		header:    http.Header{"Retry-After": {"0"}},
+		statuses:  []int{http.StatusTooManyRequests, http.StatusOK},
+		want:      http.StatusOK,
+		wantCalls: 2,
+	}, {
+		name:      "Retry-After above maximum backoff",
+		method:    http.MethodGet,
+		header:    http.Header{"Retry-After": {"3600"}},
+		statuses:  []int{http.StatusTooManyRequests, http.StatusOK},
+		want:      http.StatusOK,
+		wantCalls: 2,
+	}, {
+		name:      "5xx with backoff",
+		method:    http.MethodGet,
+		statuses:  []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+		want:      http.StatusOK,
+		wantCalls: 3,
+	}, {
+		name:      "max retries",
+		method:    http.MethodDelete,
+		statuses:  []int{http.StatusInternalServerError},
+		want:      http.StatusInternalServerError,
+		wantCalls: 3,
+	}, {
+		name:      "not idempotent",
+		method:    http.MethodPost,
+		statuses:  []int{http.StatusTooManyRequests, http.StatusOK},
+		want:      http.StatusTooManyRequests,
+		wantCalls: 1,
+	}, {
+		name:      "client error",
+		method:    http.MethodGet,
+		statuses:  []int{http.StatusNotFound, http.StatusOK},
+		want:      http.StatusNotFound,
+		wantCalls: 1,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, calls := newFlakyServer(t, tc.header, tc.statuses...)
+			client := RetryableHTTPClient(srv.Client(), WithMaxRetries(2), WithBackoff(time.Millisecond, 10*time.Millisecond))
+
+			req, err := http.NewRequestWithContext(context.Background(), tc.method, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.want {
+				t.Fatalf("expected status %d, got %d", tc.want, resp.StatusCode)
+			}
+			if got := calls.Load(); got != tc.wantCalls {
+				t.Fatalf("expected %d calls, got %d", tc.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestRetryableHTTPClient_Body(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	client := RetryableHTTPClient(srv.Client(), WithBackoff(time.Millisecond, time.Millisecond))
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPut, srv.URL, strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if len(bodies) != 2 || bodies[0] != "payload" || bodies[1] != "payload" {
+		t.Fatalf("expected body to be sent twice, got %q", bodies)
+	}
+}
+
+func TestRetryableHTTPClient_ContextCancelled(t *testing.T) {
+	srv, calls := newFlakyServer(t, http.Header{"Retry-After": {"60"}}, http.StatusTooManyRequests)
+	client := RetryableHTTPClient(srv.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Do(req) //nolint:bodyclose // no response is returned on error
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected 1 call, got %d", got)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// SpecVersion is the version of the JSON schema produced by ExportSpec. It is
+// incremented whenever a backwards incompatible change is made to the schema.
+const SpecVersion = 1
+
+// Spec is the root object of the JSON document produced by ExportSpec.
+type Spec struct {
+	// Version is the version of the schema, see SpecVersion.
+	Version int `json:"version"`
+	// Command is the specification of the root command.
+	Command CommandSpec `json:"command"`
+}
+
+// CommandSpec describes a single command in the command tree.
+type CommandSpec struct {
+	// Name is the name of the command (first word in Usage).
+	Name string `json:"name"`
+	// Path is the full path of the command, including its parents.
+	Path string `json:"path"`
+	// Args is the argument syntax of the command (Usage without the name).
+	Args string `json:"args,omitempty"`
+	// Aliases are alternative names of the command.
+	Aliases []string `json:"aliases,omitempty"`
+	// Short is the short description of the command.
+	Short string `json:"short,omitempty"`
+	// Long is the long description of the command.
+	Long string `json:"long,omitempty"`
+	// Example contains examples of how to use the command.
+	Example string `json:"example,omitempty"`
+	// Hidden is true if the command is hidden from the help output.
+	Hidden bool `json:"hidden,omitempty"`
+	// Flags are the flags defined on this command.
+	Flags []FlagSpec `json:"flags,omitempty"`
+	// InheritedFlags are the persistent flags inherited from parent commands.
+	// It is only populated in the JSON help output, ExportSpec lists those
+	// flags on the command defining them.
+	InheritedFlags []FlagSpec `json:"inheritedFlags,omitempty"`
+	// SubCommands are the subcommands of the command.
+	SubCommands []CommandSpec `json:"subCommands,omitempty"`
+}
+
+// FlagSpec describes a single flag.
+type FlagSpec struct {
+	// Name is the long name of the flag.
+	Name string `json:"name"`
+	// Shorthand is the short name of the flag.
+	Shorthand string `json:"shorthand,omitempty"`
+	// Type is the type of the flag value (e.g. "string", "int", "stringSlice").
+	Type string `json:"type"`
+	// Usage is the description of the flag.
+	Usage string `json:"usage,omitempty"`
+	// Default is the default value of the flag formatted as a string.
+	Default string `json:"default,omitempty"`
+	// Required is true if the flag needs to be supplied.
+	Required bool `json:"required,omitempty"`
+	// Persistent is true if the flag is propagated to subcommands.
+	Persistent bool `json:"persistent,omitempty"`
+	// Hidden is true if the flag is hidden from the help output.
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+// ExportSpec builds the command tree for the provided Command and returns a
+// JSON document describing it. The document does not depend on cobra
+// internals and is meant to be consumed by external tooling (e.g. SDK
+// generators). The schema is versioned, see SpecVersion.
+func (e *Ecdysis) ExportSpec(c Command) ([]byte, error) {
+	cmd, err := e.BuildCobraCommand(c)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := Spec{
+		Version: SpecVersion,
+		Command: newCommandSpec(cmd),
+	}
+	out, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal spec: %w", err)
+	}
+	return out, nil
+}
+
+func newCommandSpec(cmd *cobra.Command) CommandSpec {
+	spec := CommandSpec{
+		Name:    cmd.Name(),
+		Path:    cmd.CommandPath(),
+		Aliases: cmd.Aliases,
+		Short:   cmd.Short,
+		Long:    cmd.Long,
+		Example: cmd.Example,
+		Hidden:  cmd.Hidden,
+	}
+	if _, args, ok := strings.Cut(cmd.Use, " "); ok {
+		spec.Args = strings.TrimSpace(args)
+	}
+
+	persistent := cmd.PersistentFlags()
+	cmd.NonInheritedFlags().VisitAll(func(f *pflag.Flag) {
+		spec.Flags = append(spec.Flags, newFlagSpec(f, persistent.Lookup(f.Name) != nil))
+	})
+
+	for _, sub := range cmd.Commands() {
+		spec.SubCommands = append(spec.SubCommands, newCommandSpec(sub))
+	}
+
+	return spec
+}
+
+// newHelpSpec returns the specification of the command used in the JSON help
+// output. Contrary to newCommandSpec it also contains inherited flags.
+func newHelpSpec(cmd *cobra.Command) CommandSpec {
+	spec := newCommandSpec(cmd)
+	cmd.InheritedFlags().VisitAll(func(f *pflag.Flag) {
+		spec.InheritedFlags = append(spec.InheritedFlags, newFlagSpec(f, true))
+	})
+	return spec
+}
+
+func newFlagSpec(f *pflag.Flag, persistent bool) FlagSpec {
+	spec := FlagSpec{
+		Name:       f.Name,
+		Shorthand:  f.Shorthand,
+		Type:       f.Value.Type(),
+		Usage:      f.Usage,
+		Default:    f.DefValue,
+		Persistent: persistent,
+		Hidden:     f.Hidden,
+	}
+	if v, ok := f.Annotations[cobra.BashCompOneRequiredFlag]; ok && len(v) > 0 && v[0] == "true" {
+		spec.Required = true
+	}
+	return spec
+}
@@ -0,0 +1,128 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type specRootCmd struct {
+	flags struct {
+		Verbose bool `long:"verbose" short:"v" usage:"enable verbose output" persistent:"true"`
+	}
+}
+
+var (
+	_ CommandWithFlags       = (*specRootCmd)(nil)
+	_ CommandWithDocs        = (*specRootCmd)(nil)
+	_ CommandWithSubCommands = (*specRootCmd)(nil)
+)
+
+func (c *specRootCmd) Usage() string { return "root" }
+func (c *specRootCmd) Flags() []Flag { return BuildFlags(&c.flags) }
+func (c *specRootCmd) Docs() Docs    { return Docs{Short: "root command"} }
+func (c *specRootCmd) SubCommands() []Command {
+	return []Command{&specSubCmd{}}
+}
+
+type specSubCmd struct {
+	flags struct {
+		Name  string   `long:"name" short:"n" usage:"resource name" required:"true"`
+		Tags  []string `long:"tags" usage:"resource tags"`
+		Debug bool     `long:"debug" usage:"debug mode" hidden:"true"`
+	}
+}
+
+var (
+	_ CommandWithFlags   = (*specSubCmd)(nil)
+	_ CommandWithDocs    = (*specSubCmd)(nil)
+	_ CommandWithAliases = (*specSubCmd)(nil)
+)
+
+func (c *specSubCmd) Usage() string     { return "create [--name NAME] FILE" }
+func (c *specSubCmd) Aliases() []string { return []string{"add"} }
+func (c *specSubCmd) Docs() Docs {
+	return Docs{Short: "create a resource", Example: "root create -n foo file.yaml"}
+}
+func (c *specSubCmd) Flags() []Flag {
+	flags := BuildFlags(&c.flags)
+	flags.SetDefault("tags", []string{"a", "b"})
+	return flags
+}
+
+func TestExportSpec(t *testing.T) {
+	want := `{
+  "version": 1,
+  "command": {
+    "name": "root",
+    "path": "root",
+    "short": "root command",
+    "flags": [
+      {
+        "name": "verbose",
+        "shorthand": "v",
+        "type": "bool",
+        "usage": "enable verbose output",
+        "default": "false",
+        "persistent": true
+      }
+    ],
+    "subCommands": [
+      {
+        "name": "create",
+        "path": "root create",
+        "args": "[--name NAME] FILE",
+        "aliases": [
+          "add"
+        ],
+        "short": "create a resource",
+        "example": "root create -n foo file.yaml",
+        "flags": [
+          {
+            "name": "debug",
+            "type": "bool",
+            "usage": "debug mode",
+            "default": "false",
+            "hidden": true
+          },
+          {
+            "name": "name",
+            "shorthand": "n",
+            "type": "string",
+            "usage": "resource name (required)",
+            "required": true
+          },
+          {
+            "name": "tags",
+            "type": "stringSlice",
+            "usage": "resource tags",
+            "default": "[a,b]"
+          }
+        ]
+      }
+    ]
+  }
+}`
+
+	got, err := New().ExportSpec(&specRootCmd{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// maxSuggestionDistance is the maximum Levenshtein distance between a typed
+// name and a known name for the known name to be suggested.
+const maxSuggestionDistance = 2
+
+// suggestFlag returns the name of the visible flag closest to the typed name.
+// It returns false if no flag is close enough.
+func suggestFlag(flags *pflag.FlagSet, typed string) (string, bool) {
+	var candidates []string
+	flags.VisitAll(func(f *pflag.Flag) {
+		if !f.Hidden {
+			candidates = append(candidates, f.Name)
+		}
+	})
+	return closestMatch(candidates, typed)
+}
+
+// closestMatch returns the candidate with the smallest Levenshtein distance to
+// the typed name, as long as the distance does not exceed
+// maxSuggestionDistance. Candidates are expected to be sorted, the first one
+// wins on ties.
+func closestMatch(candidates []string, typed string) (string, bool) {
+	best, bestDistance := "", maxSuggestionDistance+1
+	for _, c := range candidates {
+		d := levenshtein(strings.ToLower(typed), strings.ToLower(c))
+		if d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best, best != ""
+}
+
+// levenshtein returns the Levenshtein distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
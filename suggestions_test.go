@@ -0,0 +1,72 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"io"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "name", b: "name", want: 0},
+		{a: "nmae", b: "name", want: 2},
+		{a: "nam", b: "name", want: 1},
+		{a: "kitten", b: "sitting", want: 3},
+	}
+	for _, tc := range testCases {
+		if got := levenshtein(tc.a, tc.b); got != tc.want {
+			t.Errorf("levenshtein(%q, %q): expected %d, got %d", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
+
+func TestFlagSuggestionsDecorator(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{{
+		name:    "typo",
+		args:    []string{"--nmae", "foo"},
+		wantErr: "unknown flag: --nmae\n\nDid you mean this?\n\t--name",
+	}, {
+		name:    "inherited flag",
+		args:    []string{"--verbse"},
+		wantErr: "unknown flag: --verbse\n\nDid you mean this?\n\t--verbose",
+	}, {
+		name:    "no match",
+		args:    []string{"--something"},
+		wantErr: "unknown flag: --something",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := New().MustBuildCobraCommand(&specRootCmd{})
+			cmd.SetArgs(append([]string{"create"}, tc.args...))
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			err := cmd.Execute()
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
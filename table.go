@@ -0,0 +1,149 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Table is tabular output of a command, e.g. the result of a list command.
+type Table struct {
+	// Headers contains the column names.
+	Headers []string
+	// Rows contains the cells of each row, in the same order as Headers.
+	Rows [][]string
+}
+
+// SelectColumns returns a table containing only the selected columns in the
+// selected order. Column names are matched case-insensitively. An error is
+// returned if a column does not exist.
+func (t Table) SelectColumns(columns []string) (Table, error) {
+	indices := make([]int, len(columns))
+	for i, col := range columns {
+		idx := t.columnIndex(col)
+		if idx == -1 {
+			return Table{}, fmt.Errorf("unknown column %q, available columns: %s", col, strings.Join(t.Headers, ", "))
+		}
+		indices[i] = idx
+	}
+
+	selected := Table{
+		Headers: make([]string, len(indices)),
+		Rows:    make([][]string, len(t.Rows)),
+	}
+	for i, idx := range indices {
+		selected.Headers[i] = t.Headers[idx]
+	}
+	for r, row := range t.Rows {
+		selected.Rows[r] = make([]string, len(indices))
+		for i, idx := range indices {
+			if idx < len(row) {
+				selected.Rows[r][i] = row[idx]
+			}
+		}
+	}
+	return selected, nil
+}
+
+// SortBy returns a table with the rows sorted by the column (matched
+// case-insensitively). If all values in the column are numbers, they are
+// compared numerically, otherwise they are compared as strings. The sort is
+// stable. An error is returned if the column does not exist.
+func (t Table) SortBy(column string, desc bool) (Table, error) {
+	idx := t.columnIndex(column)
+	if idx == -1 {
+		return Table{}, fmt.Errorf("unknown column %q, available columns: %s", column, strings.Join(t.Headers, ", "))
+	}
+
+	cell := func(row []string) string {
+		if idx < len(row) {
+			return row[idx]
+		}
+		return ""
+	}
+
+	numeric := true
+	for _, row := range t.Rows {
+		if _, err := strconv.ParseFloat(cell(row), 64); err != nil {
+			numeric = false
+			break
+		}
+	}
+
+	sorted := Table{
+		Headers: t.Headers,
+		Rows:    slices.Clone(t.Rows),
+	}
+	slices.SortStableFunc(sorted.Rows, func(a, b []string) int {
+		var c int
+		if numeric {
+			x, _ := strconv.ParseFloat(cell(a), 64)
+			y, _ := strconv.ParseFloat(cell(b), 64)
+			c = cmp.Compare(x, y)
+		} else {
+			c = strings.Compare(cell(a), cell(b))
+		}
+		if desc {
+			return -c
+		}
+		return c
+	})
+	return sorted, nil
+}
+
+// parseSortBy parses the value of the flag --sort-by in the format
+// <column>[:asc|desc].
+func parseSortBy(s string) (column string, desc bool, err error) {
+	column, dir, _ := strings.Cut(s, ":")
+	switch strings.ToLower(dir) {
+	case "", "asc":
+		return column, false, nil
+	case "desc":
+		return column, true, nil
+	default:
+		return "", false, fmt.Errorf("invalid sort direction %q, expected \"asc\" or \"desc\"", dir)
+	}
+}
+
+// Render writes the table to w, columns are aligned and separated by spaces.
+func (t Table) Render(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(tw, strings.Join(t.Headers, "\t")); err != nil {
+		return err //nolint:wrapcheck // no additional context to add
+	}
+	for _, row := range t.Rows {
+		if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+			return err //nolint:wrapcheck // no additional context to add
+		}
+	}
+	return tw.Flush() //nolint:wrapcheck // no additional context to add
+}
+
+// columnIndex returns the index of the column with the name (matched
+// case-insensitively) or -1 if the column does not exist.
+func (t Table) columnIndex(name string) int {
+	for i, h := range t.Headers {
+		if strings.EqualFold(h, name) {
+			return i
+		}
+	}
+	return -1
+}
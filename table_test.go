@@ -0,0 +1,142 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecdysis
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testCmdWithTable struct {
+	table Table
+}
+
+var _ CommandWithTable = (*testCmdWithTable)(nil)
+
+func (c *testCmdWithTable) Usage() string                        { return "list" }
+func (c *testCmdWithTable) Table(context.Context) (Table, error) { return c.table, nil }
+
+func newTestTable() Table {
+	return Table{
+		Headers: []string{"ID", "NAME", "STATUS"},
+		Rows: [][]string{
+			{"2", "generator", "running"},
+			{"10", "file-sink", "stopped"},
+		},
+	}
+}
+
+func TestCommandWithTableDecorator(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{{
+		name: "all columns",
+		args: []string{},
+		want: "ID   NAME        STATUS\n" +
+			"2    generator   running\n" +
+			"10   file-sink   stopped\n",
+	}, {
+		name: "subset",
+		args: []string{"--columns", "status,name"},
+		want: "STATUS    NAME\n" +
+			"running   generator\n" +
+			"stopped   file-sink\n",
+	}, {
+		name: "sort by numeric column descending",
+		args: []string{"--sort-by", "id:desc", "--columns", "name"},
+		want: "NAME\n" +
+			"file-sink\n" +
+			"generator\n",
+	}, {
+		name: "sort by string column ascending",
+		args: []string{"--sort-by", "name:asc"},
+		want: "ID   NAME        STATUS\n" +
+			"10   file-sink   stopped\n" +
+			"2    generator   running\n",
+	}, {
+		name:    "sort by unknown column",
+		args:    []string{"--sort-by", "age"},
+		wantErr: `invalid flag --sort-by: unknown column "age", available columns: ID, NAME, STATUS`,
+	}, {
+		name:    "invalid sort direction",
+		args:    []string{"--sort-by", "name:up"},
+		wantErr: `invalid flag --sort-by: invalid sort direction "up", expected "asc" or "desc"`,
+	}, {
+		name:    "unknown column",
+		args:    []string{"--columns", "name,age"},
+		wantErr: `invalid flag --columns: unknown column "age", available columns: ID, NAME, STATUS`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := New().MustBuildCobraCommand(&testCmdWithTable{table: newTestTable()})
+			var out bytes.Buffer
+			cmd.SetOut(&out)
+			cmd.SetErr(io.Discard)
+			cmd.SetArgs(tc.args)
+
+			err := cmd.Execute()
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestTable_SortBy(t *testing.T) {
+	table := Table{
+		Headers: []string{"NAME", "SIZE"},
+		Rows:    [][]string{{"b", "9"}, {"c", "10"}, {"a", "1.5"}},
+	}
+
+	got, err := table.SortBy("size", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := [][]string{{"a", "1.5"}, {"b", "9"}, {"c", "10"}}
+	if diff := cmp.Diff(want, got.Rows); diff != "" {
+		t.Fatal(diff)
+	}
+
+	got, err = table.SortBy("name", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = [][]string{{"c", "10"}, {"b", "9"}, {"a", "1.5"}}
+	if diff := cmp.Diff(want, got.Rows); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// the original table is not modified
+	if table.Rows[0][0] != "b" {
+		t.Fatalf("expected original table to be unchanged, got %v", table.Rows)
+	}
+}